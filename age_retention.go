@@ -0,0 +1,120 @@
+package cacheinv
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/QuangTung97/eventx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var ageRetentionDeletedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "age_retention_deleted_events_total",
+	Help: "number of events deleted because of exceeding the max age",
+}, []string{"pipeline"})
+
+// ageRetentionJob deletes events created more than maxAge ago
+type ageRetentionJob struct {
+	repo    eventx.RetentionRepository
+	ageRepo AgeRetentionRepository
+
+	maxAge          time.Duration
+	checkInterval   time.Duration
+	deleteBatchSize uint64
+
+	metrics *jobMetrics
+}
+
+func newAgeRetentionJob(
+	repo Repository, retentionRepo eventx.RetentionRepository, conf jobConfig, metrics *jobMetrics,
+) *ageRetentionJob {
+	ageRepo, ok := repo.(AgeRetentionRepository)
+	if !ok {
+		panic("cacheinv: age retention is not supported by the repository")
+	}
+	if conf.retentionMaxAge <= 0 {
+		panic("cacheinv: retention max age must be greater than 0")
+	}
+
+	return &ageRetentionJob{
+		repo:    retentionRepo,
+		ageRepo: ageRepo,
+
+		maxAge:          conf.retentionMaxAge,
+		checkInterval:   conf.ageRetentionCheckInterval,
+		deleteBatchSize: conf.ageRetentionDeleteBatchSize,
+
+		metrics: metrics,
+	}
+}
+
+func (j *ageRetentionJob) run(ctx context.Context) {
+	for {
+		err := j.deleteExpiredEvents(ctx)
+		if err != nil && ctx.Err() == nil && !errors.Is(err, errRetentionBlocked) {
+			log.Println("[ERROR] age retention job:", err)
+			j.metrics.errorTotal.WithLabelValues("retention").Add(1)
+		}
+
+		select {
+		case <-time.After(j.checkInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deleteExpiredEvents deletes the expired events in batches of sequence numbers.
+// The latest expired event is kept, because the runner needs the last event
+// to continue the sequence numbers after restarting
+func (j *ageRetentionJob) deleteExpiredEvents(ctx context.Context) error {
+	latestSeq, err := j.ageRepo.GetLatestSequenceOlderThan(ctx, j.maxAge)
+	if err != nil {
+		return err
+	}
+	if !latestSeq.Valid {
+		return nil
+	}
+
+	minSeq, err := j.repo.GetMinSequence(ctx)
+	if err != nil {
+		return err
+	}
+	if !minSeq.Valid {
+		return nil
+	}
+
+	err = j.deleteEventsBefore(ctx, uint64(minSeq.Int64), uint64(latestSeq.Int64))
+	j.observeDeletedEvents(ctx, minSeq.Int64)
+	return err
+}
+
+func (j *ageRetentionJob) deleteEventsBefore(ctx context.Context, minSeq uint64, latestSeq uint64) error {
+	for seq := minSeq; seq < latestSeq; {
+		beforeSeq := seq + j.deleteBatchSize
+		if beforeSeq > latestSeq {
+			beforeSeq = latestSeq
+		}
+
+		err := j.repo.DeleteEventsBefore(ctx, beforeSeq)
+		if err != nil {
+			return err
+		}
+		seq = beforeSeq
+	}
+	return nil
+}
+
+// observeDeletedEvents counts the events actually deleted using the min sequence numbers.
+// A repository can delete fewer events than requested, e.g. the MySQL partition-based retention
+// only drops partitions having no newer events
+func (j *ageRetentionJob) observeDeletedEvents(ctx context.Context, prevMinSeq int64) {
+	minSeq, err := j.repo.GetMinSequence(ctx)
+	if err != nil || !minSeq.Valid || minSeq.Int64 <= prevMinSeq {
+		return
+	}
+	j.metrics.ageRetentionDeletedEvents.Add(float64(minSeq.Int64 - prevMinSeq))
+}
@@ -0,0 +1,80 @@
+package cacheinv
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// ageRetentionRepoTest keeps the events with sequence numbers >= minSeq.
+// If boundSize > 0, events are only deleted in whole ranges of boundSize sequence numbers
+type ageRetentionRepoTest struct {
+	minSeq    uint64
+	latestSeq uint64
+	boundSize uint64
+}
+
+func (r *ageRetentionRepoTest) GetMinSequence(context.Context) (sql.NullInt64, error) {
+	return sql.NullInt64{Valid: true, Int64: int64(r.minSeq)}, nil
+}
+
+func (r *ageRetentionRepoTest) DeleteEventsBefore(_ context.Context, beforeSeq uint64) error {
+	if r.boundSize > 0 {
+		beforeSeq = beforeSeq / r.boundSize * r.boundSize
+	}
+	if beforeSeq > r.minSeq {
+		r.minSeq = beforeSeq
+	}
+	return nil
+}
+
+func (r *ageRetentionRepoTest) GetLatestSequenceOlderThan(context.Context, time.Duration) (sql.NullInt64, error) {
+	return sql.NullInt64{Valid: true, Int64: int64(r.latestSeq)}, nil
+}
+
+func newAgeRetentionJobTest(repo *ageRetentionRepoTest, pipelineName string) *ageRetentionJob {
+	return &ageRetentionJob{
+		repo:    repo,
+		ageRepo: repo,
+
+		maxAge:          time.Hour,
+		deleteBatchSize: 3,
+
+		metrics: newJobMetrics(pipelineName),
+	}
+}
+
+func TestAgeRetentionJob_DeleteExpiredEvents(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		repo := &ageRetentionRepoTest{minSeq: 1, latestSeq: 12}
+		j := newAgeRetentionJobTest(repo, "age-retention-normal")
+
+		err := j.deleteExpiredEvents(context.Background())
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, uint64(12), repo.minSeq)
+		assert.Equal(t, float64(11), testutil.ToFloat64(j.metrics.ageRetentionDeletedEvents))
+	})
+
+	t.Run("count only deleted events", func(t *testing.T) {
+		repo := &ageRetentionRepoTest{minSeq: 1, latestSeq: 12, boundSize: 10}
+		j := newAgeRetentionJobTest(repo, "age-retention-bound")
+
+		err := j.deleteExpiredEvents(context.Background())
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, uint64(10), repo.minSeq)
+		assert.Equal(t, float64(9), testutil.ToFloat64(j.metrics.ageRetentionDeletedEvents))
+
+		// nothing more is deleted
+		err = j.deleteExpiredEvents(context.Background())
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, uint64(10), repo.minSeq)
+		assert.Equal(t, float64(9), testutil.ToFloat64(j.metrics.ageRetentionDeletedEvents))
+	})
+}
@@ -0,0 +1,228 @@
+package cacheinv
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var cacheConsumerAppliedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_consumer_applied_events_total",
+	Help: "number of events applied to each cache server",
+}, []string{"pipeline", "server_name"})
+
+var cacheConsumerSkippedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_consumer_skipped_events_total",
+	Help: "number of events skipped because not targeting the cache server",
+}, []string{"pipeline", "server_name"})
+
+// patternDelete is a pattern of the event with sequence number *eventSeq*
+type patternDelete struct {
+	eventSeq uint64
+	pattern  string
+}
+
+// invalidateGroup is the payloads of consecutive events with the same operation (and the same TTL for expire)
+type invalidateGroup struct {
+	op Operation
+
+	keys      []string
+	patterns  []patternDelete
+	versioned []EventPayload
+	ttl       time.Duration
+}
+
+// invalidateBatch groups the payloads of a batch of events, the groups are applied in the order of events
+type invalidateBatch struct {
+	serverID   int64
+	serverName string
+	expireTTL  time.Duration
+
+	groups []*invalidateGroup
+
+	applied int
+	skipped int
+}
+
+func (b *invalidateBatch) add(eventSeq uint64, payload EventPayload) {
+	var ttl time.Duration
+	if payload.Op == OperationExpire {
+		ttl = b.expireTTL
+		if payload.TTL > 0 {
+			ttl = time.Duration(payload.TTL) * time.Second
+		}
+	}
+
+	var g *invalidateGroup
+	if len(b.groups) > 0 {
+		g = b.groups[len(b.groups)-1]
+	}
+	if g == nil || g.op != payload.Op || g.ttl != ttl {
+		g = &invalidateGroup{op: payload.Op, ttl: ttl}
+		b.groups = append(b.groups, g)
+	}
+
+	switch payload.Op {
+	case OperationPattern:
+		for _, pattern := range payload.Keys {
+			g.patterns = append(g.patterns, patternDelete{eventSeq: eventSeq, pattern: pattern})
+		}
+	case OperationVersionedDelete:
+		g.versioned = append(g.versioned, payload)
+	default:
+		g.keys = append(g.keys, payload.Keys...)
+	}
+}
+
+func (j *InvalidatorJob) newInvalidateBatch(
+	serverID int64, serverName string, events []InvalidateEvent,
+) *invalidateBatch {
+	b := &invalidateBatch{
+		serverID:   serverID,
+		serverName: serverName,
+		expireTTL:  j.conf.expireTTL,
+	}
+
+	serverGroups := j.conf.serverGroups[serverID]
+
+	for _, e := range events {
+		payload, err := ParseEventDataWithDefault(e.Data, j.conf.defaultOperation)
+		if err != nil {
+			log.Printf("[ERROR] skip event id = %d: %v\n", e.ID, err)
+			j.metrics.errorTotal.WithLabelValues("payload").Add(1)
+			continue
+		}
+
+		if !payload.AppliesTo(serverName, serverGroups) {
+			b.skipped++
+			continue
+		}
+		b.applied++
+
+		b.add(e.GetSequence(), payload)
+	}
+
+	return b
+}
+
+func (j *InvalidatorJob) applyEvents(serverID int64, serverName string, events []InvalidateEvent) error {
+	b := j.newInvalidateBatch(serverID, serverName, events)
+
+	for _, g := range b.groups {
+		err := j.applyGroup(b, g)
+		if err != nil {
+			return err
+		}
+	}
+
+	j.metrics.consumerAppliedEvents.WithLabelValues(serverName).Add(float64(b.applied))
+	j.metrics.consumerSkippedEvents.WithLabelValues(serverName).Add(float64(b.skipped))
+	return nil
+}
+
+func (j *InvalidatorJob) applyGroup(b *invalidateBatch, g *invalidateGroup) error {
+	switch g.op {
+	case OperationPattern:
+		return j.deletePatterns(b, g)
+	case OperationTag:
+		return j.deleteTags(b, g)
+	case OperationVersionedDelete:
+		return j.deleteVersionedKeys(b, g)
+	case OperationExpire:
+		return j.expireKeys(b, g)
+	default:
+		return j.deleteKeys(b, g)
+	}
+}
+
+func (j *InvalidatorJob) logUnsupportedOperation(op Operation, serverName string) {
+	log.Printf("[ERROR] skip '%s' invalidation, not supported by server '%s'\n", op, serverName)
+	j.metrics.errorTotal.WithLabelValues("payload").Add(1)
+}
+
+func (j *InvalidatorJob) deleteKeys(b *invalidateBatch, g *invalidateGroup) error {
+	if len(g.keys) == 0 {
+		return nil
+	}
+
+	err := j.client.DeleteCacheKeys(j.ctx, b.serverID, g.keys)
+	if err != nil {
+		return err
+	}
+
+	deleter, ok := j.doubleDeleters[b.serverID]
+	if ok {
+		deleter.schedule(g.keys)
+	}
+	return nil
+}
+
+func (j *InvalidatorJob) deletePatterns(b *invalidateBatch, g *invalidateGroup) error {
+	if len(g.patterns) == 0 {
+		return nil
+	}
+
+	patternClient, ok := j.client.(PatternClient)
+	if !ok {
+		j.logUnsupportedOperation(OperationPattern, b.serverName)
+		return nil
+	}
+
+	for _, p := range g.patterns {
+		err := patternClient.DeleteCachePattern(j.ctx, b.serverID, p.eventSeq, p.pattern)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *InvalidatorJob) deleteTags(b *invalidateBatch, g *invalidateGroup) error {
+	if len(g.keys) == 0 {
+		return nil
+	}
+
+	tagClient, ok := j.client.(TagClient)
+	if !ok {
+		j.logUnsupportedOperation(OperationTag, b.serverName)
+		return nil
+	}
+
+	return tagClient.DeleteCacheTags(j.ctx, b.serverID, g.keys)
+}
+
+func (j *InvalidatorJob) deleteVersionedKeys(b *invalidateBatch, g *invalidateGroup) error {
+	if len(g.versioned) == 0 {
+		return nil
+	}
+
+	versionedClient, ok := j.client.(VersionedClient)
+	if !ok {
+		j.logUnsupportedOperation(OperationVersionedDelete, b.serverName)
+		return nil
+	}
+
+	for _, payload := range g.versioned {
+		err := versionedClient.DeleteVersionedKeys(j.ctx, b.serverID, payload.Keys, payload.KeyVersion)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *InvalidatorJob) expireKeys(b *invalidateBatch, g *invalidateGroup) error {
+	if len(g.keys) == 0 {
+		return nil
+	}
+
+	expireClient, ok := j.client.(ExpireClient)
+	if !ok {
+		j.logUnsupportedOperation(OperationExpire, b.serverName)
+		return nil
+	}
+
+	return expireClient.ExpireCacheKeys(j.ctx, b.serverID, g.keys, g.ttl)
+}
@@ -0,0 +1,76 @@
+package cacheinv
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newApplyTestEvents(dataList ...string) []InvalidateEvent {
+	events := make([]InvalidateEvent, 0, len(dataList))
+	for i, data := range dataList {
+		events = append(events, InvalidateEvent{
+			ID:   int64(i + 1),
+			Seq:  sql.NullInt64{Valid: true, Int64: int64(i + 1)},
+			Data: data,
+		})
+	}
+	return events
+}
+
+func TestNewInvalidateBatch(t *testing.T) {
+	t.Run("groups in event order", func(t *testing.T) {
+		j := &InvalidatorJob{
+			conf:    newJobConfig(nil),
+			metrics: newJobMetrics(DefaultPipelineName),
+		}
+
+		b := j.newInvalidateBatch(11, "redis:11", newApplyTestEvents(
+			"key01",
+			"key02",
+			`{"v":1,"op":"expire","keys":["key01"]}`,
+			`{"v":1,"op":"expire","keys":["key03"]}`,
+			`{"v":1,"op":"expire","keys":["key04"],"ttl":5}`,
+			"key01",
+			`{"v":1,"op":"pattern","keys":["user:*"]}`,
+			`{"v":1,"op":"versioned_delete","keys":["key05"],"version":3}`,
+			`{"v":1,"op":"tag","keys":["tag01"]}`,
+			"key06",
+		))
+
+		assert.Equal(t, []*invalidateGroup{
+			{op: OperationDelete, keys: []string{"key01", "key02"}},
+			{op: OperationExpire, keys: []string{"key01", "key03"}, ttl: 10 * time.Second},
+			{op: OperationExpire, keys: []string{"key04"}, ttl: 5 * time.Second},
+			{op: OperationDelete, keys: []string{"key01"}},
+			{op: OperationPattern, patterns: []patternDelete{{eventSeq: 7, pattern: "user:*"}}},
+			{op: OperationVersionedDelete, versioned: []EventPayload{
+				{Version: 1, Op: OperationVersionedDelete, Keys: []string{"key05"}, KeyVersion: 3},
+			}},
+			{op: OperationTag, keys: []string{"tag01"}},
+			{op: OperationDelete, keys: []string{"key06"}},
+		}, b.groups)
+		assert.Equal(t, 10, b.applied)
+	})
+
+	t.Run("skip events of other servers", func(t *testing.T) {
+		j := &InvalidatorJob{
+			conf:    newJobConfig(nil),
+			metrics: newJobMetrics(DefaultPipelineName),
+		}
+
+		b := j.newInvalidateBatch(11, "redis:11", newApplyTestEvents(
+			"key01",
+			`{"v":1,"keys":["key02"],"servers":["redis:12"]}`,
+			"key03",
+		))
+
+		assert.Equal(t, []*invalidateGroup{
+			{op: OperationDelete, keys: []string{"key01", "key03"}},
+		}, b.groups)
+		assert.Equal(t, 2, b.applied)
+		assert.Equal(t, 1, b.skipped)
+	})
+}
@@ -3,9 +3,10 @@ package cacheinv
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"log"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/QuangTung97/eventx"
 	"github.com/prometheus/client_golang/prometheus"
@@ -56,6 +57,22 @@ type Repository interface {
 	SetLastSequence(ctx context.Context, serverName string, seq int64) error
 }
 
+// AgeRetentionRepository is an optional interface of Repository for deleting events by age,
+// required by RetentionModeAge and RetentionModeSizeOrAge
+type AgeRetentionRepository interface {
+	// GetLatestSequenceOlderThan returns the sequence number of the latest created event
+	// among events created more than *maxAge* ago (except events with null sequence numbers),
+	// returns null if no such events existed
+	GetLatestSequenceOlderThan(ctx context.Context, maxAge time.Duration) (sql.NullInt64, error)
+}
+
+// MaintenanceRepository is an optional interface of Repository for periodic maintenance tasks,
+// e.g. pre-creating partitions of the event table
+type MaintenanceRepository interface {
+	// RunMaintenance is called periodically by InvalidatorJob, see WithMaintenanceInterval
+	RunMaintenance(ctx context.Context) error
+}
+
 // Client ...
 type Client interface {
 	// GetServerIDs ...
@@ -68,6 +85,32 @@ type Client interface {
 	DeleteCacheKeys(ctx context.Context, serverID int64, keys []string) error
 }
 
+// PatternClient is an optional interface of Client for supporting OperationPattern
+type PatternClient interface {
+	// DeleteCachePattern deletes all cache keys matching the glob-style *pattern*.
+	// The *eventSeq* is the sequence number of the event, for scoping the progress of the deletion to the event
+	DeleteCachePattern(ctx context.Context, serverID int64, eventSeq uint64, pattern string) error
+}
+
+// TagClient is an optional interface of Client for supporting OperationTag
+type TagClient interface {
+	// DeleteCacheTags deletes all cache keys belonging to the *tags*, along with the tags themselves
+	DeleteCacheTags(ctx context.Context, serverID int64, tags []string) error
+}
+
+// VersionedClient is an optional interface of Client for supporting OperationVersionedDelete
+type VersionedClient interface {
+	// DeleteVersionedKeys deletes the cache keys whose stored versions are lower than *version*,
+	// keys without stored versions are always deleted
+	DeleteVersionedKeys(ctx context.Context, serverID int64, keys []string, version uint64) error
+}
+
+// ExpireClient is an optional interface of Client for supporting OperationExpire
+type ExpireClient interface {
+	// ExpireCacheKeys sets the TTL of the cache keys to *ttl* instead of deleting them
+	ExpireCacheKeys(ctx context.Context, serverID int64, keys []string, ttl time.Duration) error
+}
+
 // =================================
 // Invalidator Job
 // =================================
@@ -82,14 +125,20 @@ type InvalidatorJob struct {
 	repo   Repository
 	client Client
 
-	runner    *eventx.Runner[InvalidateEvent]
-	retention *eventx.RetentionJob[InvalidateEvent]
+	runner       *eventx.Runner[InvalidateEvent]
+	retention    *eventx.RetentionJob[InvalidateEvent]
+	ageRetention *ageRetentionJob
+
+	doubleDeleters map[int64]*doubleDeleter
+	gapRecovery    *gapRecovery
+
+	metrics *jobMetrics
 }
 
 var invalidatorJobErrorTotal = promauto.NewCounterVec(prometheus.CounterOpts{
 	Name: "invalidator_job_error_total",
 	Help: "number of errors happened",
-}, []string{"service_type"})
+}, []string{"pipeline", "service_type"})
 
 // NewInvalidatorJob ...
 func NewInvalidatorJob(repo Repository, client Client, options ...Option) *InvalidatorJob {
@@ -105,12 +154,14 @@ func NewInvalidatorJob(repo Repository, client Client, options ...Option) *Inval
 
 		repo:   repo,
 		client: client,
+
+		metrics: newJobMetrics(conf.pipelineName),
 	}
 
 	runnerOptions := []eventx.Option{
 		eventx.WithErrorLogger(func(err error) {
 			log.Println("[ERROR] eventx runner:", err)
-			invalidatorJobErrorTotal.WithLabelValues("core").Add(1)
+			j.metrics.errorTotal.WithLabelValues("core").Add(1)
 		}),
 	}
 	runnerOptions = append(runnerOptions, conf.runnerOptions...)
@@ -126,19 +177,49 @@ func NewInvalidatorJob(repo Repository, client Client, options ...Option) *Inval
 		runnerOptions...,
 	)
 
-	retentionOptions := []eventx.RetentionOption{
-		eventx.WithRetentionErrorLogger(func(err error) {
-			log.Println("[ERROR] retention job:", err)
-			invalidatorJobErrorTotal.WithLabelValues("retention").Add(1)
-		}),
+	var retentionRepo eventx.RetentionRepository = repo
+	if conf.consumerAwareRetention {
+		retentionRepo = newConsumerAwareRetention(repo, client, conf.retentionHardCap, j.metrics)
 	}
-	retentionOptions = append(retentionOptions, conf.retentionOptions...)
 
-	j.retention = eventx.NewRetentionJob[InvalidateEvent](
-		j.runner,
-		repo,
-		retentionOptions...,
-	)
+	if conf.retentionMode != RetentionModeAge {
+		retentionOptions := []eventx.RetentionOption{
+			eventx.WithRetentionErrorLogger(func(err error) {
+				if errors.Is(err, errRetentionBlocked) {
+					return
+				}
+				log.Println("[ERROR] retention job:", err)
+				j.metrics.errorTotal.WithLabelValues("retention").Add(1)
+			}),
+		}
+		retentionOptions = append(retentionOptions, conf.retentionOptions...)
+
+		j.retention = eventx.NewRetentionJob[InvalidateEvent](
+			j.runner,
+			retentionRepo,
+			retentionOptions...,
+		)
+	}
+
+	if conf.retentionMode != RetentionModeSize {
+		j.ageRetention = newAgeRetentionJob(repo, retentionRepo, conf, j.metrics)
+	}
+
+	if conf.doubleDeleteDelay > 0 {
+		j.doubleDeleters = map[int64]*doubleDeleter{}
+		for _, id := range client.GetServerIDs() {
+			serverID := id
+			j.doubleDeleters[serverID] = newDoubleDeleter(
+				conf.doubleDeleteDelay, conf.doubleDeleteMaxPendingKeys, client.GetServerName(serverID),
+				func(ctx context.Context, keys []string) error {
+					return client.DeleteCacheKeys(ctx, serverID, keys)
+				},
+				j.metrics,
+			)
+		}
+	}
+
+	j.gapRecovery = newGapRecovery(client, conf)
 
 	return j
 }
@@ -146,39 +227,59 @@ func NewInvalidatorJob(repo Repository, client Client, options ...Option) *Inval
 var cacheConsumerLastSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
 	Name: "cache_consumer_last_seq",
 	Help: "last consumed sequence number for each cache server",
-}, []string{"server_name"})
+}, []string{"pipeline", "server_name"})
 
 func (j *InvalidatorJob) runCacheRetryConsumer(serverID int64) {
+	if j.gapRecovery == nil {
+		options := j.conf.retryOptions
+		if j.conf.retryErrorLogger != nil {
+			options = append([]eventx.RetryConsumerOption{}, options...)
+			options = append(options, eventx.WithRetryConsumerErrorLogger(j.conf.retryErrorLogger))
+		}
+		j.newCacheRetryConsumer(serverID, options).RunConsumer(j.ctx)
+		return
+	}
+
+	// the consumer is restarted when the events after its offset were deleted,
+	// for running the gap recovery in getLastSequence
+	for j.ctx.Err() == nil {
+		ctx, cancel := context.WithCancel(j.ctx)
+
+		options := append([]eventx.RetryConsumerOption{}, j.conf.retryOptions...)
+		options = append(options, j.gapRecoveryRetryOptions(cancel)...)
+
+		j.newCacheRetryConsumer(serverID, options).RunConsumer(ctx)
+		cancel()
+	}
+}
+
+func (j *InvalidatorJob) newCacheRetryConsumer(
+	serverID int64, options []eventx.RetryConsumerOption,
+) *eventx.RetryConsumer[InvalidateEvent] {
 	serverName := j.client.GetServerName(serverID)
 
-	consumer := eventx.NewRetryConsumer[InvalidateEvent](
+	return eventx.NewRetryConsumer[InvalidateEvent](
 		j.runner,
 		j.repo,
 		func(ctx context.Context) (sql.NullInt64, error) {
-			lastSeq, err := j.repo.GetLastSequence(j.ctx, serverName)
+			lastSeq, err := j.getLastSequence(j.ctx, serverID, serverName)
 			if lastSeq.Valid {
-				cacheConsumerLastSeq.WithLabelValues(serverName).Set(float64(lastSeq.Int64))
+				j.metrics.consumerLastSeq.WithLabelValues(serverName).Set(float64(lastSeq.Int64))
 			}
 			return lastSeq, err
 		},
 		func(ctx context.Context, seq uint64) error {
 			err := j.repo.SetLastSequence(j.ctx, serverName, int64(seq))
 			if err == nil {
-				cacheConsumerLastSeq.WithLabelValues(serverName).Set(float64(seq))
+				j.metrics.consumerLastSeq.WithLabelValues(serverName).Set(float64(seq))
 			}
 			return err
 		},
 		func(ctx context.Context, events []InvalidateEvent) error {
-			var keys []string
-			for _, e := range events {
-				keys = append(keys, strings.Split(e.Data, ",")...)
-			}
-			return j.client.DeleteCacheKeys(j.ctx, serverID, keys)
+			return j.applyEvents(serverID, serverName, events)
 		},
-		j.conf.retryOptions...,
+		options...,
 	)
-
-	consumer.RunConsumer(j.ctx)
 }
 
 func (j *InvalidatorJob) runConsumers(wg *sync.WaitGroup) {
@@ -194,6 +295,17 @@ func (j *InvalidatorJob) runConsumers(wg *sync.WaitGroup) {
 			j.runCacheRetryConsumer(serverID)
 		}()
 	}
+
+	wg.Add(len(j.doubleDeleters))
+
+	for _, deleter := range j.doubleDeleters {
+		d := deleter
+
+		go func() {
+			defer wg.Done()
+			d.run(j.ctx)
+		}()
+	}
 }
 
 // Run ...
@@ -209,16 +321,53 @@ func (j *InvalidatorJob) Run() {
 
 	j.runConsumers(&wg)
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	if j.retention != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		j.retention.RunJob(j.ctx)
-	}()
+			j.retention.RunJob(j.ctx)
+		}()
+	}
+
+	if j.ageRetention != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			j.ageRetention.run(j.ctx)
+		}()
+	}
+
+	maintenanceRepo, ok := j.repo.(MaintenanceRepository)
+	if ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			j.runMaintenance(maintenanceRepo)
+		}()
+	}
 
 	wg.Wait()
 }
 
+func (j *InvalidatorJob) runMaintenance(repo MaintenanceRepository) {
+	for {
+		err := repo.RunMaintenance(j.ctx)
+		if err != nil && j.ctx.Err() == nil {
+			log.Println("[ERROR] repository maintenance:", err)
+			j.metrics.errorTotal.WithLabelValues("maintenance").Add(1)
+		}
+
+		select {
+		case <-time.After(j.conf.maintenanceInterval):
+		case <-j.ctx.Done():
+			return
+		}
+	}
+}
+
 // Notify ...
 func (j *InvalidatorJob) Notify() {
 	j.runner.Signal()
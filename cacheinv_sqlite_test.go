@@ -0,0 +1,71 @@
+package cacheinv_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/memtest"
+	"github.com/QuangTung97/cacheinv/migrate"
+	"github.com/QuangTung97/cacheinv/sqlite"
+)
+
+func TestInvalidatorJob_SQLite(t *testing.T) {
+	db := sqlx.MustConnect("sqlite3", "file:"+filepath.Join(t.TempDir(), "cache_inv.db")+"?_busy_timeout=5000")
+	defer func() { _ = db.Close() }()
+	db.SetMaxOpenConns(1)
+
+	_, err := migrate.New(db, sqlite.Migrations(), migrate.TableNames{
+		EventTableName:  "invalidate_events",
+		OffsetTableName: "invalidate_offsets",
+	}).Up(context.Background())
+	assert.Equal(t, nil, err)
+
+	repo := sqlite.NewRepository(db, "invalidate_events", "invalidate_offsets")
+	client := memtest.NewClient(11, 12)
+
+	j := &jobTest{
+		db:   db,
+		repo: repo,
+		inv:  cacheinv.NewInvalidatorJob(repo, client),
+	}
+
+	// consumers without offsets start from the last event
+	for _, serverName := range client.GetServerNames() {
+		err := repo.SetLastSequence(context.Background(), serverName, 0)
+		assert.Equal(t, nil, err)
+	}
+
+	j.run()
+
+	j.insertEvents(
+		cacheinv.InvalidateEvent{
+			Data: "key01,key02",
+		},
+		cacheinv.InvalidateEvent{
+			Data: `{"v":1,"keys":["key03"],"servers":["memtest:12"]}`,
+		},
+	)
+
+	j.inv.Notify()
+
+	time.Sleep(500 * time.Millisecond)
+	j.waitCompleted()
+
+	lastSeq, err := repo.GetLastSequence(context.Background(), "memtest:11")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), lastSeq.Int64)
+
+	lastSeq, err = repo.GetLastSequence(context.Background(), "memtest:12")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), lastSeq.Int64)
+
+	assert.Equal(t, []string{"key01", "key02"}, client.GetDeletedKeys(11))
+	assert.Equal(t, []string{"key01", "key02", "key03"}, client.GetDeletedKeys(12))
+}
@@ -2,7 +2,6 @@ package cacheinv_test
 
 import (
 	"context"
-	_ "embed"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/migrate"
 	"github.com/QuangTung97/cacheinv/mysql"
 	redis_client "github.com/QuangTung97/cacheinv/redis"
 )
@@ -27,9 +27,6 @@ type jobTest struct {
 	inv *cacheinv.InvalidatorJob
 }
 
-//go:embed mysql/migration.sql
-var migrateSQL string
-
 var dbOnce sync.Once
 var globalDB *sqlx.DB
 
@@ -39,7 +36,13 @@ func initDB() *sqlx.DB {
 			"mysql",
 			"root:1@tcp(localhost:3306)/cache_inv?parseTime=true&multiStatements=true",
 		)
-		globalDB.MustExec(migrateSQL)
+		_, err := migrate.New(globalDB, mysql.Migrations(), migrate.TableNames{
+			EventTableName:  "invalidate_events",
+			OffsetTableName: "invalidate_offsets",
+		}).Up(context.Background())
+		if err != nil {
+			panic(err)
+		}
 	})
 	return globalDB
 }
@@ -176,6 +179,275 @@ func TestInvalidatorJob(t *testing.T) {
 		assert.Equal(t, "", val)
 	})
 
+	t.Run("do delete with json payload", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.run()
+
+		client1 := j.clients[11]
+		client2 := j.clients[12]
+
+		err := client1.Set(context.Background(), "key,01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client2.Set(context.Background(), "key,01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		// must not be deleted by the invalid JSON event
+		err = client2.Set(context.Background(), `{"v":1`, []byte("data02"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		data, err := cacheinv.EncodeEventPayload(cacheinv.EventPayload{
+			Keys:    []string{"key,01"},
+			Servers: []string{"redis:11"},
+		})
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: data,
+			},
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		lastSeq, err := j.repo.GetLastSequence(context.Background(), "redis:11")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(2), lastSeq.Int64)
+
+		lastSeq, err = j.repo.GetLastSequence(context.Background(), "redis:12")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(2), lastSeq.Int64)
+
+		// Check redis
+		val, err := client1.Get(context.Background(), "key,01").Result()
+		assert.Equal(t, redis.Nil, err)
+		assert.Equal(t, "", val)
+
+		val, err = client2.Get(context.Background(), "key,01").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data01", val)
+
+		val, err = client2.Get(context.Background(), `{"v":1`).Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data02", val)
+	})
+
+	t.Run("do delete with server groups", func(t *testing.T) {
+		j := newJobTest(t, cacheinv.WithServerGroup("tenant01", 12))
+
+		j.run()
+
+		client1 := j.clients[11]
+		client2 := j.clients[12]
+
+		err := client1.Set(context.Background(), "key01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client2.Set(context.Background(), "key01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"keys":["key01"],"groups":["tenant01"]}`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		lastSeq, err := j.repo.GetLastSequence(context.Background(), "redis:11")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(1), lastSeq.Int64)
+
+		// Check redis
+		val, err := client1.Get(context.Background(), "key01").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data01", val)
+
+		val, err = client2.Get(context.Background(), "key01").Result()
+		assert.Equal(t, redis.Nil, err)
+		assert.Equal(t, "", val)
+	})
+
+	t.Run("do delete by pattern", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.run()
+
+		client1 := j.clients[11]
+
+		err := client1.Set(context.Background(), "user:42:01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.Set(context.Background(), "user:42:02", []byte("data02"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.Set(context.Background(), "user:43:01", []byte("data03"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"op":"pattern","keys":["user:42:*"]}`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		keys, err := client1.Keys(context.Background(), "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"user:43:01"}, keys)
+	})
+
+	t.Run("do delete by tag", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.run()
+
+		client1 := j.clients[11]
+
+		err := client1.Set(context.Background(), "product:99:01", []byte("data01"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.Set(context.Background(), "product:99:02", []byte("data02"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.SAdd(context.Background(), "tag:product:99", "product:99:01", "product:99:02").Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.Set(context.Background(), "product:98:01", []byte("data03"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"op":"tag","keys":["tag:product:99"]}`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		keys, err := client1.Keys(context.Background(), "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"product:98:01"}, keys)
+	})
+
+	t.Run("do versioned delete", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.run()
+
+		client1 := j.clients[11]
+
+		err := client1.MSet(context.Background(),
+			"key01", "data01", "key01:version", "10",
+			"key02", "data02", "key02:version", "20",
+		).Err()
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"op":"versioned_delete","keys":["key01","key02"],"version":15}`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		keys, err := client1.Keys(context.Background(), "key0?").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"key02"}, keys)
+	})
+
+	t.Run("do expire with default invalidate mode", func(t *testing.T) {
+		j := newJobTest(t,
+			cacheinv.WithDefaultOperation(cacheinv.OperationExpire),
+			cacheinv.WithExpireTTL(5*time.Second),
+		)
+
+		j.run()
+
+		client1 := j.clients[11]
+
+		err := client1.MSet(context.Background(), "key01", "data01", "key02", "data02", "key03", "data03").Err()
+		assert.Equal(t, nil, err)
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: "key01",
+			},
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"op":"expire","keys":["key02"],"ttl":20}`,
+			},
+			cacheinv.InvalidateEvent{
+				Data: `{"v":1,"op":"delete","keys":["key03"]}`,
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(500 * time.Millisecond)
+		j.waitCompleted()
+
+		keys, err := client1.Keys(context.Background(), "key0?").Result()
+		assert.Equal(t, nil, err)
+		assert.ElementsMatch(t, []string{"key01", "key02"}, keys)
+
+		ttl, err := client1.PTTL(context.Background(), "key01").Result()
+		assert.Equal(t, nil, err)
+		assert.Greater(t, ttl, 4*time.Second)
+		assert.LessOrEqual(t, ttl, 5*time.Second)
+
+		ttl, err = client1.PTTL(context.Background(), "key02").Result()
+		assert.Equal(t, nil, err)
+		assert.Greater(t, ttl, 19*time.Second)
+		assert.LessOrEqual(t, ttl, 20*time.Second)
+	})
+
+	t.Run("do double delete", func(t *testing.T) {
+		j := newJobTest(t, cacheinv.WithDoubleDelete(500*time.Millisecond, 1000))
+
+		j.run()
+
+		client1 := j.clients[11]
+
+		j.insertEvents(
+			cacheinv.InvalidateEvent{
+				Data: "key01",
+			},
+		)
+
+		j.inv.Notify()
+
+		time.Sleep(200 * time.Millisecond)
+
+		// repopulate stale value after the first delete
+		err := client1.Set(context.Background(), "key01", []byte("stale"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		time.Sleep(600 * time.Millisecond)
+		j.waitCompleted()
+
+		val, err := client1.Get(context.Background(), "key01").Result()
+		assert.Equal(t, redis.Nil, err)
+		assert.Equal(t, "", val)
+	})
+
 	t.Run("do retention", func(t *testing.T) {
 		j := newJobTest(t,
 			cacheinv.WithRunnerOptions(eventx.WithCoreStoredEventsSize(512)),
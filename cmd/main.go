@@ -1,9 +1,15 @@
 package main
 
 import (
+	"os"
+
 	"github.com/QuangTung97/cacheinv/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		server.Migrate(os.Args[2:])
+		return
+	}
 	server.Start()
 }
@@ -16,21 +16,67 @@ type Config struct {
 	EventTableName  string `mapstructure:"event_table_name"`
 	OffsetTableName string `mapstructure:"offset_table_name"`
 
-	EventRetentionSize uint32        `mapstructure:"event_retention_size"`
-	DBScanDuration     time.Duration `mapstructure:"db_scan_duration"`
+	EventRetentionSize   uint32        `mapstructure:"event_retention_size"`
+	EventRetentionMode   RetentionMode `mapstructure:"event_retention_mode"`
+	EventRetentionMaxAge time.Duration `mapstructure:"event_retention_max_age"`
+
+	EventRetentionConsumerAware bool   `mapstructure:"event_retention_consumer_aware"`
+	EventRetentionHardCap       uint32 `mapstructure:"event_retention_hard_cap"`
+
+	GapRecoveryAction     GapRecoveryAction `mapstructure:"gap_recovery_action"`
+	GapRecoveryWebhookURL string            `mapstructure:"gap_recovery_webhook_url"`
+
+	DBScanDuration time.Duration `mapstructure:"db_scan_duration"`
+
+	DoubleDeleteDelay          time.Duration `mapstructure:"double_delete_delay"`
+	DoubleDeleteMaxPendingKeys int           `mapstructure:"double_delete_max_pending_keys"`
+
+	DefaultInvalidateMode InvalidateMode `mapstructure:"default_invalidate_mode"`
+	ExpireTTL             time.Duration  `mapstructure:"expire_ttl"`
 
 	NotifyAccessToken string `mapstructure:"notify_access_token"`
 
-	DBType DBType      `mapstructure:"db_type"`
-	MySQL  MySQLConfig `mapstructure:"mysql"`
+	DBType   DBType         `mapstructure:"db_type"`
+	MySQL    MySQLConfig    `mapstructure:"mysql"`
+	Postgres PostgresConfig `mapstructure:"postgres"`
+	SQLite   SQLiteConfig   `mapstructure:"sqlite"`
+
+	AutoMigrate bool `mapstructure:"auto_migrate"`
 
 	ClientType ClientType `mapstructure:"client_type"`
 
 	RedisNumServers int           `mapstructure:"redis_num_servers"`
 	RedisServers    []RedisConfig `mapstructure:"-"`
 
+	RedisDeleteUnlink    bool `mapstructure:"redis_delete_unlink"`
+	RedisDeleteChunkSize int  `mapstructure:"redis_delete_chunk_size"`
+
 	MemcacheNumServers int              `mapstructure:"memcache_num_servers"`
 	MemcacheServers    []MemcacheConfig `mapstructure:"-"`
+
+	// Pipelines is the list of independent pipelines, each with its own database, tables and cache servers.
+	// If empty, a single pipeline named 'default' is built from the top-level config, see GetPipelines
+	Pipelines []PipelineConfig `mapstructure:"pipelines"`
+}
+
+// DefaultPipelineName is the name of the pipeline built from the top-level config
+const DefaultPipelineName = "default"
+
+// PipelineConfig ...
+type PipelineConfig struct {
+	Name string `mapstructure:"name"`
+
+	EventTableName  string `mapstructure:"event_table_name"`
+	OffsetTableName string `mapstructure:"offset_table_name"`
+
+	DBType   DBType         `mapstructure:"db_type"`
+	MySQL    MySQLConfig    `mapstructure:"mysql"`
+	Postgres PostgresConfig `mapstructure:"postgres"`
+	SQLite   SQLiteConfig   `mapstructure:"sqlite"`
+
+	ClientType      ClientType       `mapstructure:"client_type"`
+	RedisServers    []RedisConfig    `mapstructure:"redis_servers"`
+	MemcacheServers []MemcacheConfig `mapstructure:"memcache_servers"`
 }
 
 // DBType ...
@@ -39,6 +85,10 @@ type DBType string
 const (
 	// DBTypeMySQL ...
 	DBTypeMySQL DBType = "mysql"
+	// DBTypePostgres ...
+	DBTypePostgres DBType = "postgres"
+	// DBTypeSQLite ...
+	DBTypeSQLite DBType = "sqlite"
 )
 
 // ClientType ...
@@ -63,20 +113,151 @@ type MySQLConfig struct {
 	MaxOpenConns    uint32        `mapstructure:"max_open_conns"`
 	MaxIdleConns    uint32        `mapstructure:"max_idle_conns"`
 	MaxConnIdleTime time.Duration `mapstructure:"max_conn_idle_time"`
+
+	PartitionSize    uint64 `mapstructure:"partition_size"`
+	FuturePartitions int    `mapstructure:"future_partitions"`
+
+	// ReplicaHost is the host of the read replica, using the same username, password, database and options
+	ReplicaHost string `mapstructure:"replica_host"`
+	// ReplicaPort is the port of the read replica, default is the port of the primary
+	ReplicaPort uint16 `mapstructure:"replica_port"`
+}
+
+// PostgresConfig ...
+type PostgresConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     uint16 `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Database string `mapstructure:"database"`
+	Options  string `mapstructure:"options"`
+
+	MaxOpenConns    uint32        `mapstructure:"max_open_conns"`
+	MaxIdleConns    uint32        `mapstructure:"max_idle_conns"`
+	MaxConnIdleTime time.Duration `mapstructure:"max_conn_idle_time"`
+}
+
+// SQLiteConfig ...
+type SQLiteConfig struct {
+	Path    string `mapstructure:"path"`
+	Options string `mapstructure:"options"`
 }
 
 // RedisConfig ...
 type RedisConfig struct {
-	ID   uint32
-	Addr string
+	ID     uint32   `mapstructure:"id"`
+	Addr   string   `mapstructure:"addr"`
+	Groups []string `mapstructure:"groups"`
+
+	Type RedisServerType `mapstructure:"type"`
+	// ClusterAddrs is the list of seed nodes of the redis cluster, only for the cluster type
+	ClusterAddrs []string `mapstructure:"cluster_addrs"`
+
+	// SentinelMasterName is the name of the master monitored by the sentinels, only for the sentinel type
+	SentinelMasterName string `mapstructure:"sentinel_master_name"`
+	// SentinelAddrs is the list of addresses of the sentinels, only for the sentinel type
+	SentinelAddrs []string `mapstructure:"sentinel_addrs"`
+	// SentinelUsername is the ACL user of the sentinels, only for the sentinel type
+	SentinelUsername string `mapstructure:"sentinel_username"`
+	// SentinelPassword should only be used for testing, use SentinelPasswordEnv or SentinelPasswordFile instead
+	SentinelPassword string `mapstructure:"sentinel_password"`
+	// SentinelPasswordEnv is the name of the environment variable containing the password of the sentinels
+	SentinelPasswordEnv string `mapstructure:"sentinel_password_env"`
+	// SentinelPasswordFile is the path of the file containing the password of the sentinels
+	SentinelPasswordFile string `mapstructure:"sentinel_password_file"`
+
+	RedisConnConfig `mapstructure:",squash"`
 }
 
+// RedisServerType ...
+type RedisServerType string
+
+const (
+	// RedisServerTypeStandalone is a single redis server at 'addr'
+	RedisServerTypeStandalone RedisServerType = "standalone"
+	// RedisServerTypeCluster is a redis cluster discovered from the seed nodes 'cluster_addrs'
+	RedisServerTypeCluster RedisServerType = "cluster"
+	// RedisServerTypeSentinel is the master 'sentinel_master_name' discovered from the sentinels 'sentinel_addrs'
+	RedisServerTypeSentinel RedisServerType = "sentinel"
+)
+
 // MemcacheConfig ...
 type MemcacheConfig struct {
-	ID   uint32
-	Addr string
+	ID         uint32             `mapstructure:"id"`
+	Addr       string             `mapstructure:"addr"`
+	DeleteMode MemcacheDeleteMode `mapstructure:"delete_mode"`
+	Groups     []string           `mapstructure:"groups"`
+
+	// Nodes is the pool of memcache servers, each key is stored on one node selected by HashScheme.
+	// Only one of Addr and Nodes can be set
+	Nodes      []string           `mapstructure:"nodes"`
+	HashScheme MemcacheHashScheme `mapstructure:"hash_scheme"`
+}
+
+// MemcacheHashScheme ...
+type MemcacheHashScheme string
+
+const (
+	// MemcacheHashSchemeKetama is the consistent hashing of libketama, the default of pools
+	MemcacheHashSchemeKetama MemcacheHashScheme = "ketama"
+	// MemcacheHashSchemeRendezvous is the rendezvous (highest random weight) hashing
+	MemcacheHashSchemeRendezvous MemcacheHashScheme = "rendezvous"
+)
+
+// GetNodes returns the addresses of the nodes, a server with only Addr is a single node pool
+func (c MemcacheConfig) GetNodes() []string {
+	if len(c.Nodes) > 0 {
+		return c.Nodes
+	}
+	return []string{c.Addr}
 }
 
+// MemcacheDeleteMode ...
+type MemcacheDeleteMode string
+
+const (
+	// MemcacheDeleteModeDelete deletes the cache items
+	MemcacheDeleteModeDelete MemcacheDeleteMode = "delete"
+	// MemcacheDeleteModeInvalidate marks the cache items as stale with a new CAS value
+	MemcacheDeleteModeInvalidate MemcacheDeleteMode = "invalidate"
+)
+
+// InvalidateMode ...
+type InvalidateMode string
+
+const (
+	// InvalidateModeDelete deletes the cache keys
+	InvalidateModeDelete InvalidateMode = "delete"
+	// InvalidateModeExpire sets short TTLs (expire_ttl) for the cache keys instead of deleting them
+	InvalidateModeExpire InvalidateMode = "expire"
+)
+
+// RetentionMode ...
+type RetentionMode string
+
+const (
+	// RetentionModeSize keeps at most event_retention_size events
+	RetentionModeSize RetentionMode = "size"
+	// RetentionModeAge deletes events created more than event_retention_max_age ago
+	RetentionModeAge RetentionMode = "age"
+	// RetentionModeSizeOrAge deletes events when either event_retention_size or event_retention_max_age exceeded
+	RetentionModeSizeOrAge RetentionMode = "size_or_age"
+)
+
+// GapRecoveryAction ...
+type GapRecoveryAction string
+
+const (
+	// GapRecoveryActionNone keeps retrying on the events deleted before being consumed
+	GapRecoveryActionNone GapRecoveryAction = "none"
+	// GapRecoveryActionAlert only logs and counts in metrics, then skips the deleted events
+	GapRecoveryActionAlert GapRecoveryAction = "alert"
+	// GapRecoveryActionFlush flushes the whole cache server (FLUSHDB on redis, flush_all on memcache)
+	GapRecoveryActionFlush GapRecoveryAction = "flush"
+	// GapRecoveryActionWebhook sends a POST request to gap_recovery_webhook_url
+	GapRecoveryActionWebhook GapRecoveryAction = "webhook"
+)
+
 // Load ...
 func Load() Config {
 	vip := viper.New()
@@ -113,8 +294,22 @@ func loadConfigWithViper(vip *viper.Viper) Config {
 		panic(err)
 	}
 
+	if len(cfg.DBType) == 0 {
+		cfg.DBType = DBTypeMySQL
+	}
+	if len(cfg.DefaultInvalidateMode) == 0 {
+		cfg.DefaultInvalidateMode = InvalidateModeDelete
+	}
+	if len(cfg.EventRetentionMode) == 0 {
+		cfg.EventRetentionMode = RetentionModeSize
+	}
+	if len(cfg.GapRecoveryAction) == 0 {
+		cfg.GapRecoveryAction = GapRecoveryActionNone
+	}
+
 	loadRedisServersConfig(&cfg, vip)
 	loadMemcacheServersConfig(&cfg, vip)
+	setPipelinesDefault(&cfg)
 
 	cfg.validateConfig()
 
@@ -131,16 +326,46 @@ func loadRedisServersConfig(cfg *Config, vip *viper.Viper) {
 		addrKey := key + "_addr"
 		addr := vip.GetString(addrKey)
 
+		serverType := RedisServerType(vip.GetString(key + "_type"))
+		if len(serverType) == 0 {
+			serverType = RedisServerTypeStandalone
+		}
+
 		if serverID == 0 {
 			panic(fmt.Sprintf("missing config key '%s'", idKey))
 		}
-		if len(addr) == 0 {
-			panic(fmt.Sprintf("missing config key '%s'", addrKey))
+
+		// the keys of the addresses required by the server type
+		requiredKeys := []string{addrKey}
+		switch serverType {
+		case RedisServerTypeCluster:
+			requiredKeys = []string{key + "_cluster_addrs"}
+		case RedisServerTypeSentinel:
+			requiredKeys = []string{key + "_sentinel_master_name", key + "_sentinel_addrs"}
+		}
+		for _, requiredKey := range requiredKeys {
+			if !vip.IsSet(requiredKey) {
+				panic(fmt.Sprintf("missing config key '%s'", requiredKey))
+			}
 		}
 
 		cfg.RedisServers = append(cfg.RedisServers, RedisConfig{
-			ID:   serverID,
-			Addr: addr,
+			ID:     serverID,
+			Addr:   addr,
+			Groups: vip.GetStringSlice(key + "_groups"),
+
+			Type:         serverType,
+			ClusterAddrs: vip.GetStringSlice(key + "_cluster_addrs"),
+
+			SentinelMasterName: vip.GetString(key + "_sentinel_master_name"),
+			SentinelAddrs:      vip.GetStringSlice(key + "_sentinel_addrs"),
+
+			SentinelUsername:     vip.GetString(key + "_sentinel_username"),
+			SentinelPassword:     vip.GetString(key + "_sentinel_password"),
+			SentinelPasswordEnv:  vip.GetString(key + "_sentinel_password_env"),
+			SentinelPasswordFile: vip.GetString(key + "_sentinel_password_file"),
+
+			RedisConnConfig: loadRedisConnConfig(vip, key),
 		})
 	}
 }
@@ -155,17 +380,94 @@ func loadMemcacheServersConfig(cfg *Config, vip *viper.Viper) {
 		addrKey := key + "_addr"
 		addr := vip.GetString(addrKey)
 
+		deleteMode := MemcacheDeleteMode(vip.GetString(key + "_delete_mode"))
+		if len(deleteMode) == 0 {
+			deleteMode = MemcacheDeleteModeDelete
+		}
+
 		if serverID == 0 {
 			panic(fmt.Sprintf("missing config key '%s'", idKey))
 		}
-		if len(addr) == 0 {
+		nodes := vip.GetStringSlice(key + "_nodes")
+		if len(addr) == 0 && len(nodes) == 0 {
 			panic(fmt.Sprintf("missing config key '%s'", addrKey))
 		}
 
-		cfg.MemcacheServers = append(cfg.MemcacheServers, MemcacheConfig{
-			ID:   serverID,
-			Addr: addr,
-		})
+		serverConf := MemcacheConfig{
+			ID:         serverID,
+			Addr:       addr,
+			DeleteMode: deleteMode,
+			Groups:     vip.GetStringSlice(key + "_groups"),
+
+			Nodes:      nodes,
+			HashScheme: MemcacheHashScheme(vip.GetString(key + "_hash_scheme")),
+		}
+		serverConf.setHashSchemeDefault()
+		cfg.MemcacheServers = append(cfg.MemcacheServers, serverConf)
+	}
+}
+
+func setPipelinesDefault(cfg *Config) {
+	for i := range cfg.Pipelines {
+		p := &cfg.Pipelines[i]
+
+		if len(p.EventTableName) == 0 {
+			p.EventTableName = cfg.EventTableName
+		}
+		if len(p.OffsetTableName) == 0 {
+			p.OffsetTableName = cfg.OffsetTableName
+		}
+		if len(p.DBType) == 0 {
+			p.DBType = DBTypeMySQL
+		}
+
+		p.setServersDefault()
+	}
+}
+
+func (c *PipelineConfig) setServersDefault() {
+	for i := range c.RedisServers {
+		if len(c.RedisServers[i].Type) == 0 {
+			c.RedisServers[i].Type = RedisServerTypeStandalone
+		}
+	}
+	for i := range c.MemcacheServers {
+		if len(c.MemcacheServers[i].DeleteMode) == 0 {
+			c.MemcacheServers[i].DeleteMode = MemcacheDeleteModeDelete
+		}
+		c.MemcacheServers[i].setHashSchemeDefault()
+	}
+}
+
+func (c *MemcacheConfig) setHashSchemeDefault() {
+	if len(c.Nodes) > 0 && len(c.HashScheme) == 0 {
+		c.HashScheme = MemcacheHashSchemeKetama
+	}
+}
+
+// GetPipelines returns the configured pipelines,
+// or a single pipeline named DefaultPipelineName built from the top-level config if none configured
+func (c Config) GetPipelines() []PipelineConfig {
+	if len(c.Pipelines) > 0 {
+		return c.Pipelines
+	}
+
+	return []PipelineConfig{
+		{
+			Name: DefaultPipelineName,
+
+			EventTableName:  c.EventTableName,
+			OffsetTableName: c.OffsetTableName,
+
+			DBType:   c.DBType,
+			MySQL:    c.MySQL,
+			Postgres: c.Postgres,
+			SQLite:   c.SQLite,
+
+			ClientType:      c.ClientType,
+			RedisServers:    c.RedisServers,
+			MemcacheServers: c.MemcacheServers,
+		},
 	}
 }
 
@@ -184,7 +486,128 @@ func (c MySQLConfig) PrintDSN() string {
 	return c.dsnWithPass("[SECRET]")
 }
 
+// replicaConfig returns the config of the read replica, only valid if ReplicaHost is not empty
+func (c MySQLConfig) replicaConfig() MySQLConfig {
+	replica := c
+	replica.Host = c.ReplicaHost
+	if c.ReplicaPort > 0 {
+		replica.Port = c.ReplicaPort
+	}
+	return replica
+}
+
+// ReplicaDSN ...
+func (c MySQLConfig) ReplicaDSN() string {
+	return c.replicaConfig().DSN()
+}
+
+// PrintReplicaDSN ...
+func (c MySQLConfig) PrintReplicaDSN() string {
+	return c.replicaConfig().PrintDSN()
+}
+
+// DSN ...
+func (c PostgresConfig) DSN() string {
+	return c.dsnWithUserInfo(url.UserPassword(c.Username, c.Password).String())
+}
+
+func (c PostgresConfig) dsnWithUserInfo(userInfo string) string {
+	return fmt.Sprintf("postgres://%s@%s:%d/%s?%s", userInfo, c.Host, c.Port, c.Database, c.Options)
+}
+
+// PrintDSN ...
+func (c PostgresConfig) PrintDSN() string {
+	return c.dsnWithUserInfo(url.User(c.Username).String() + ":[SECRET]")
+}
+
+// DSN ...
+func (c SQLiteConfig) DSN() string {
+	if len(c.Options) == 0 {
+		return c.Path
+	}
+	return fmt.Sprintf("file:%s?%s", c.Path, c.Options)
+}
+
 func (c Config) validateConfig() {
+	if c.DoubleDeleteDelay > 0 && c.DoubleDeleteMaxPendingKeys <= 0 {
+		panic("double_delete_max_pending_keys must be greater than 0")
+	}
+	if c.RedisDeleteChunkSize < 0 {
+		panic("redis_delete_chunk_size must not be negative")
+	}
+
+	c.validateRetentionConfig()
+	c.validateGapRecoveryConfig()
+
+	switch c.DefaultInvalidateMode {
+	case "", InvalidateModeDelete:
+	case InvalidateModeExpire:
+		if c.ExpireTTL <= 0 {
+			panic("expire_ttl must be greater than 0")
+		}
+	default:
+		panic(fmt.Sprintf("invalid default invalidate mode '%s'", c.DefaultInvalidateMode))
+	}
+
+	c.validatePipelines()
+}
+
+// pipelineTable identifies a table of a database used by a pipeline
+type pipelineTable struct {
+	dbType DBType
+	dsn    string
+	table  string
+}
+
+func (c Config) validatePipelines() {
+	pipelineNames := map[string]struct{}{}
+	eventTables := map[pipelineTable]string{}
+	offsetTables := map[pipelineTable]string{}
+
+	for _, p := range c.GetPipelines() {
+		if len(p.Name) == 0 {
+			panic("pipeline name must not be empty")
+		}
+
+		_, existed := pipelineNames[p.Name]
+		if existed {
+			panic(fmt.Sprintf("duplicated pipeline name '%s'", p.Name))
+		}
+		pipelineNames[p.Name] = struct{}{}
+
+		p.validatePipelineConfig()
+
+		checkPipelineTable(eventTables, p.pipelineTable(p.EventTableName), p.Name, "event")
+		checkPipelineTable(offsetTables, p.pipelineTable(p.OffsetTableName), p.Name, "offset")
+	}
+}
+
+// checkPipelineTable panics if *table* is already used by another pipeline in *tables*
+func checkPipelineTable(tables map[pipelineTable]string, table pipelineTable, name string, kind string) {
+	prevName, existed := tables[table]
+	if existed {
+		panic(fmt.Sprintf(
+			"pipelines '%s' and '%s' must not share the same %s table '%s'",
+			prevName, name, kind, table.table,
+		))
+	}
+	tables[table] = name
+}
+
+func (c PipelineConfig) pipelineTable(table string) pipelineTable {
+	switch c.DBType {
+	case DBTypePostgres:
+		return pipelineTable{dbType: DBTypePostgres, dsn: c.Postgres.DSN(), table: table}
+	case DBTypeSQLite:
+		return pipelineTable{dbType: DBTypeSQLite, dsn: c.SQLite.DSN(), table: table}
+	default:
+		return pipelineTable{dbType: DBTypeMySQL, dsn: c.MySQL.DSN(), table: table}
+	}
+}
+
+func (c PipelineConfig) validatePipelineConfig() {
+	c.validateDBConfig()
+
 	switch c.ClientType {
 	case ClientTypeRedis:
 		c.validateRedisConfig()
@@ -197,9 +620,57 @@ func (c Config) validateConfig() {
 	}
 }
 
-func (c Config) validateRedisConfig() {
+func (c PipelineConfig) validateDBConfig() {
+	switch c.DBType {
+	case "", DBTypeMySQL:
+		if c.MySQL.PartitionSize > 0 && c.MySQL.FuturePartitions <= 0 {
+			panic("mysql future_partitions must be greater than 0")
+		}
+		if c.MySQL.ReplicaPort > 0 && len(c.MySQL.ReplicaHost) == 0 {
+			panic("mysql replica_host must not be empty")
+		}
+	case DBTypePostgres:
+	case DBTypeSQLite:
+		if len(c.SQLite.Path) == 0 {
+			panic("sqlite path must not be empty")
+		}
+	default:
+		panic(fmt.Sprintf("invalid db type '%s'", c.DBType))
+	}
+}
+
+func (c Config) validateRetentionConfig() {
+	switch c.EventRetentionMode {
+	case "", RetentionModeSize:
+	case RetentionModeAge, RetentionModeSizeOrAge:
+		if c.EventRetentionMaxAge <= 0 {
+			panic("event_retention_max_age must be greater than 0")
+		}
+	default:
+		panic(fmt.Sprintf("invalid event retention mode '%s'", c.EventRetentionMode))
+	}
+
+	if c.EventRetentionHardCap > 0 && c.EventRetentionHardCap < c.EventRetentionSize {
+		panic("event_retention_hard_cap must not be less than event_retention_size")
+	}
+}
+
+func (c Config) validateGapRecoveryConfig() {
+	switch c.GapRecoveryAction {
+	case "", GapRecoveryActionNone, GapRecoveryActionAlert, GapRecoveryActionFlush:
+	case GapRecoveryActionWebhook:
+		if len(c.GapRecoveryWebhookURL) == 0 {
+			panic("gap_recovery_webhook_url must not be empty")
+		}
+	default:
+		panic(fmt.Sprintf("invalid gap recovery action '%s'", c.GapRecoveryAction))
+	}
+}
+
+func (c PipelineConfig) validateRedisConfig() {
 	serverIDs := map[uint32]struct{}{}
 	serverAddrs := map[string]struct{}{}
+	sentinelMasters := map[sentinelMaster]struct{}{}
 
 	if len(c.RedisServers) == 0 {
 		panic("redis server list must not be empty")
@@ -209,9 +680,8 @@ func (c Config) validateRedisConfig() {
 		if s.ID <= 0 {
 			panic("redis server id must not be empty")
 		}
-		if len(s.Addr) == 0 {
-			panic("redis server address must not be empty")
-		}
+
+		addrs := s.getAddrs()
 
 		_, existed := serverIDs[s.ID]
 		if existed {
@@ -219,15 +689,86 @@ func (c Config) validateRedisConfig() {
 		}
 		serverIDs[s.ID] = struct{}{}
 
-		_, existed = serverAddrs[s.Addr]
+		if s.Type == RedisServerTypeSentinel {
+			// the same sentinels can monitor multiple masters
+			s.checkSentinelMasters(sentinelMasters)
+			validatePassword("sentinel_password", s.SentinelPassword, s.SentinelPasswordEnv, s.SentinelPasswordFile)
+			addrs = nil
+		}
+
+		for _, addr := range addrs {
+			_, existed = serverAddrs[addr]
+			if existed {
+				panic(fmt.Sprintf("duplicated redis server address '%s'", addr))
+			}
+			serverAddrs[addr] = struct{}{}
+		}
+
+		validateServerGroups("redis", s.Groups)
+		s.validateRedisConnConfig(s.Type)
+	}
+}
+
+// sentinelMaster is a master monitored by a sentinel, different sentinel deployments can use the same master name
+type sentinelMaster struct {
+	sentinelAddr string
+	masterName   string
+}
+
+// checkSentinelMasters panics if the master was already monitored by one of the sentinels of the server
+func (c RedisConfig) checkSentinelMasters(sentinelMasters map[sentinelMaster]struct{}) {
+	for _, addr := range c.SentinelAddrs {
+		key := sentinelMaster{sentinelAddr: addr, masterName: c.SentinelMasterName}
+		_, existed := sentinelMasters[key]
 		if existed {
-			panic(fmt.Sprintf("duplicated redis server address '%s'", s.Addr))
+			panic(fmt.Sprintf(
+				"duplicated redis sentinel master name '%s' of sentinel '%s'", c.SentinelMasterName, addr,
+			))
+		}
+		sentinelMasters[key] = struct{}{}
+	}
+}
+
+// GetSentinelPassword returns the password of the sentinels from SentinelPassword,
+// or the environment variable SentinelPasswordEnv, or the file SentinelPasswordFile
+func (c RedisConfig) GetSentinelPassword() (string, error) {
+	return getPassword(c.SentinelPassword, c.SentinelPasswordEnv, c.SentinelPasswordFile)
+}
+
+// getAddrs returns the addresses of the standalone redis, the seed nodes of the redis cluster, or the sentinels
+func (c RedisConfig) getAddrs() []string {
+	addrs := c.getAddrsByType()
+	for _, addr := range addrs {
+		if len(addr) == 0 {
+			panic("redis server address must not be empty")
 		}
-		serverAddrs[s.Addr] = struct{}{}
 	}
+	return addrs
 }
 
-func (c Config) validateMemcacheConfig() {
+func (c RedisConfig) getAddrsByType() []string {
+	switch c.Type {
+	case "", RedisServerTypeStandalone:
+		return []string{c.Addr}
+	case RedisServerTypeCluster:
+		if len(c.ClusterAddrs) == 0 {
+			panic("redis cluster address list must not be empty")
+		}
+		return c.ClusterAddrs
+	case RedisServerTypeSentinel:
+		if len(c.SentinelMasterName) == 0 {
+			panic("redis sentinel master name must not be empty")
+		}
+		if len(c.SentinelAddrs) == 0 {
+			panic("redis sentinel address list must not be empty")
+		}
+		return c.SentinelAddrs
+	default:
+		panic(fmt.Sprintf("invalid redis server type '%s'", c.Type))
+	}
+}
+
+func (c PipelineConfig) validateMemcacheConfig() {
 	serverIDs := map[uint32]struct{}{}
 	serverAddrs := map[string]struct{}{}
 
@@ -239,9 +780,6 @@ func (c Config) validateMemcacheConfig() {
 		if s.ID <= 0 {
 			panic("memcache server id must not be empty")
 		}
-		if len(s.Addr) == 0 {
-			panic("memcache server address must not be empty")
-		}
 
 		_, existed := serverIDs[s.ID]
 		if existed {
@@ -249,10 +787,57 @@ func (c Config) validateMemcacheConfig() {
 		}
 		serverIDs[s.ID] = struct{}{}
 
-		_, existed = serverAddrs[s.Addr]
-		if existed {
-			panic(fmt.Sprintf("duplicated memcache server address '%s'", s.Addr))
+		for _, addr := range s.validateNodes() {
+			_, existed = serverAddrs[addr]
+			if existed {
+				panic(fmt.Sprintf("duplicated memcache server address '%s'", addr))
+			}
+			serverAddrs[addr] = struct{}{}
+		}
+
+		validateServerGroups("memcache", s.Groups)
+
+		switch s.DeleteMode {
+		case "", MemcacheDeleteModeDelete, MemcacheDeleteModeInvalidate:
+		default:
+			panic(fmt.Sprintf("invalid memcache delete mode '%s'", s.DeleteMode))
+		}
+	}
+}
+
+// validateNodes returns the addresses of the nodes after validated
+func (c MemcacheConfig) validateNodes() []string {
+	if len(c.Nodes) == 0 {
+		if len(c.Addr) == 0 {
+			panic("memcache server address must not be empty")
+		}
+		if len(c.HashScheme) > 0 {
+			panic("memcache hash scheme must only be set with nodes")
+		}
+		return []string{c.Addr}
+	}
+
+	if len(c.Addr) > 0 {
+		panic("memcache server address and nodes must not be both set")
+	}
+	for _, node := range c.Nodes {
+		if len(node) == 0 {
+			panic("memcache server node address must not be empty")
+		}
+	}
+
+	switch c.HashScheme {
+	case MemcacheHashSchemeKetama, MemcacheHashSchemeRendezvous:
+	default:
+		panic(fmt.Sprintf("invalid memcache hash scheme '%s'", c.HashScheme))
+	}
+	return c.Nodes
+}
+
+func validateServerGroups(clientType string, groups []string) {
+	for _, group := range groups {
+		if len(group) == 0 {
+			panic(fmt.Sprintf("%s server group must not be empty", clientType))
 		}
-		serverAddrs[s.Addr] = struct{}{}
 	}
 }
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// RedisConnConfig is the connection options of a redis server
+type RedisConnConfig struct {
+	Username string `mapstructure:"username"`
+	// Password should only be used for testing, use PasswordEnv or PasswordFile instead
+	Password string `mapstructure:"password"`
+	// PasswordEnv is the name of the environment variable containing the password
+	PasswordEnv string `mapstructure:"password_env"`
+	// PasswordFile is the path of the file containing the password, e.g. a mounted secret
+	PasswordFile string `mapstructure:"password_file"`
+
+	DB int `mapstructure:"db"`
+
+	TLSEnabled            bool   `mapstructure:"tls_enabled"`
+	TLSCAFile             string `mapstructure:"tls_ca_file"`
+	TLSCertFile           string `mapstructure:"tls_cert_file"`
+	TLSKeyFile            string `mapstructure:"tls_key_file"`
+	TLSServerName         string `mapstructure:"tls_server_name"`
+	TLSInsecureSkipVerify bool   `mapstructure:"tls_insecure_skip_verify"`
+
+	// DialTimeout, ReadTimeout, WriteTimeout and PoolSize use the defaults of go-redis if zero
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	PoolSize     int           `mapstructure:"pool_size"`
+}
+
+func loadRedisConnConfig(vip *viper.Viper, key string) RedisConnConfig {
+	return RedisConnConfig{
+		Username:     vip.GetString(key + "_username"),
+		Password:     vip.GetString(key + "_password"),
+		PasswordEnv:  vip.GetString(key + "_password_env"),
+		PasswordFile: vip.GetString(key + "_password_file"),
+
+		DB: vip.GetInt(key + "_db"),
+
+		TLSEnabled:            vip.GetBool(key + "_tls_enabled"),
+		TLSCAFile:             vip.GetString(key + "_tls_ca_file"),
+		TLSCertFile:           vip.GetString(key + "_tls_cert_file"),
+		TLSKeyFile:            vip.GetString(key + "_tls_key_file"),
+		TLSServerName:         vip.GetString(key + "_tls_server_name"),
+		TLSInsecureSkipVerify: vip.GetBool(key + "_tls_insecure_skip_verify"),
+
+		DialTimeout:  vip.GetDuration(key + "_dial_timeout"),
+		ReadTimeout:  vip.GetDuration(key + "_read_timeout"),
+		WriteTimeout: vip.GetDuration(key + "_write_timeout"),
+		PoolSize:     vip.GetInt(key + "_pool_size"),
+	}
+}
+
+// GetPassword returns the password from Password, or the environment variable PasswordEnv, or the file PasswordFile
+func (c RedisConnConfig) GetPassword() (string, error) {
+	return getPassword(c.Password, c.PasswordEnv, c.PasswordFile)
+}
+
+func getPassword(password string, passwordEnv string, passwordFile string) (string, error) {
+	if len(passwordEnv) > 0 {
+		value, ok := os.LookupEnv(passwordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%s' is not set", passwordEnv)
+		}
+		return value, nil
+	}
+
+	if len(passwordFile) > 0 {
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	return password, nil
+}
+
+// NewTLSConfig returns the TLS config, or nil if TLS is not enabled
+func (c RedisConnConfig) NewTLSConfig() (*tls.Config, error) {
+	if !c.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSInsecureSkipVerify,
+	}
+
+	if len(c.TLSCAFile) > 0 {
+		data, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in '%s'", c.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.TLSCertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func (c RedisConnConfig) validateRedisConnConfig(serverType RedisServerType) {
+	validatePassword("password", c.Password, c.PasswordEnv, c.PasswordFile)
+
+	if c.DB < 0 {
+		panic("redis db must not be negative")
+	}
+	if c.DB != 0 && serverType == RedisServerTypeCluster {
+		panic("redis cluster only supports db 0")
+	}
+
+	if c.DialTimeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 {
+		panic("redis timeouts must not be negative")
+	}
+	if c.PoolSize < 0 {
+		panic("redis pool_size must not be negative")
+	}
+
+	c.validateTLSConfig()
+}
+
+// validatePassword panics if more than one of the password sources are set or the password can not be read,
+// *name* is the config key of the password, e.g. 'password' or 'sentinel_password'
+func validatePassword(name string, password string, passwordEnv string, passwordFile string) {
+	numPasswords := 0
+	for _, p := range []string{password, passwordEnv, passwordFile} {
+		if len(p) > 0 {
+			numPasswords++
+		}
+	}
+	if numPasswords > 1 {
+		panic(fmt.Sprintf("only one of redis %s, %s_env and %s_file can be set", name, name, name))
+	}
+	if _, err := getPassword(password, passwordEnv, passwordFile); err != nil {
+		panic(fmt.Sprintf("invalid redis %s: %v", strings.ReplaceAll(name, "_", " "), err))
+	}
+}
+
+func (c RedisConnConfig) validateTLSConfig() {
+	if !c.TLSEnabled {
+		if len(c.TLSCAFile) > 0 || len(c.TLSCertFile) > 0 || len(c.TLSKeyFile) > 0 {
+			panic("redis tls_enabled must be true when tls files are set")
+		}
+		return
+	}
+
+	if (len(c.TLSCertFile) == 0) != (len(c.TLSKeyFile) == 0) {
+		panic("redis tls_cert_file and tls_key_file must be set together")
+	}
+
+	if _, err := c.NewTLSConfig(); err != nil {
+		panic(fmt.Sprintf("invalid redis tls config: %v", err))
+	}
+}
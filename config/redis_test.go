@@ -0,0 +1,315 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, data, 0600)
+	assert.Equal(t, nil, err)
+	return path
+}
+
+// writeTestCert writes a self-signed certificate and its key, returns the paths of the cert and key files
+func writeTestCert(t *testing.T) (certFile string, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Equal(t, nil, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "redis"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Equal(t, nil, err)
+
+	keyData, err := x509.MarshalECPrivateKey(key)
+	assert.Equal(t, nil, err)
+
+	certFile = writeFile(t, "cert.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certData}))
+	keyFile = writeFile(t, "key.pem", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyData}))
+	return certFile, keyFile
+}
+
+func TestRedisConnConfig_GetPassword(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		c := RedisConnConfig{Password: "pass01"}
+		password, err := c.GetPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass01", password)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("TEST_REDIS_PASSWORD", "pass02")
+
+		c := RedisConnConfig{PasswordEnv: "TEST_REDIS_PASSWORD"}
+		password, err := c.GetPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass02", password)
+
+		c.PasswordEnv = "TEST_REDIS_PASSWORD_NOT_SET"
+		_, err = c.GetPassword()
+		assert.Equal(t, "environment variable 'TEST_REDIS_PASSWORD_NOT_SET' is not set", err.Error())
+	})
+
+	t.Run("file", func(t *testing.T) {
+		c := RedisConnConfig{PasswordFile: writeFile(t, "password", []byte("pass03\n"))}
+		password, err := c.GetPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass03", password)
+	})
+}
+
+func TestRedisConfig_GetSentinelPassword(t *testing.T) {
+	t.Run("password", func(t *testing.T) {
+		c := RedisConfig{SentinelPassword: "pass01"}
+		password, err := c.GetSentinelPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass01", password)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("TEST_SENTINEL_PASSWORD", "pass02")
+
+		c := RedisConfig{SentinelPasswordEnv: "TEST_SENTINEL_PASSWORD"}
+		password, err := c.GetSentinelPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass02", password)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		c := RedisConfig{SentinelPasswordFile: writeFile(t, "password", []byte("pass03\n"))}
+		password, err := c.GetSentinelPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "pass03", password)
+	})
+
+	t.Run("not affected by the redis password", func(t *testing.T) {
+		c := RedisConfig{RedisConnConfig: RedisConnConfig{Password: "pass04"}}
+		password, err := c.GetSentinelPassword()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "", password)
+	})
+}
+
+func TestRedisConnConfig_NewTLSConfig(t *testing.T) {
+	c := RedisConnConfig{}
+	tlsConfig, err := c.NewTLSConfig()
+	assert.Equal(t, nil, err)
+	assert.Nil(t, tlsConfig)
+
+	certFile, keyFile := writeTestCert(t)
+
+	c = RedisConnConfig{
+		TLSEnabled:    true,
+		TLSCAFile:     certFile,
+		TLSCertFile:   certFile,
+		TLSKeyFile:    keyFile,
+		TLSServerName: "redis",
+	}
+	tlsConfig, err = c.NewTLSConfig()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "redis", tlsConfig.ServerName)
+	assert.Equal(t, 1, len(tlsConfig.Certificates))
+	assert.NotNil(t, tlsConfig.RootCAs)
+	assert.Equal(t, false, tlsConfig.InsecureSkipVerify)
+
+	c.TLSCAFile = keyFile
+	_, err = c.NewTLSConfig()
+	assert.Equal(t, "no certificates found in '"+keyFile+"'", err.Error())
+}
+
+func TestLoadRedisConnConfig(t *testing.T) {
+	vip := viper.New()
+	vip.Set("redis_server_1_id", uint32(11))
+	vip.Set("redis_server_1_addr", "localhost:6379")
+	vip.Set("redis_server_1_username", "user01")
+	vip.Set("redis_server_1_password_env", "REDIS_PASSWORD")
+	vip.Set("redis_server_1_db", 3)
+	vip.Set("redis_server_1_tls_enabled", true)
+	vip.Set("redis_server_1_tls_ca_file", "./ca.pem")
+	vip.Set("redis_server_1_dial_timeout", "3s")
+	vip.Set("redis_server_1_read_timeout", "2s")
+	vip.Set("redis_server_1_write_timeout", "1s")
+	vip.Set("redis_server_1_pool_size", 20)
+
+	cfg := Config{
+		RedisNumServers: 1,
+	}
+	loadRedisServersConfig(&cfg, vip)
+
+	assert.Equal(t, RedisConnConfig{
+		Username:    "user01",
+		PasswordEnv: "REDIS_PASSWORD",
+
+		DB: 3,
+
+		TLSEnabled: true,
+		TLSCAFile:  "./ca.pem",
+
+		DialTimeout:  3 * time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		PoolSize:     20,
+	}, cfg.RedisServers[0].RedisConnConfig)
+}
+
+func TestValidateRedisConnConfig(t *testing.T) {
+	newConfig := func(connConf RedisConnConfig) Config {
+		return Config{
+			ClientType: ClientTypeRedis,
+			RedisServers: []RedisConfig{
+				{ID: 11, Addr: "localhost:6379", RedisConnConfig: connConf},
+			},
+		}
+	}
+
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name     string
+		connConf RedisConnConfig
+		expected string
+	}{
+		{
+			name:     "multiple passwords",
+			connConf: RedisConnConfig{Password: "pass01", PasswordFile: "./password"},
+			expected: "only one of redis password, password_env and password_file can be set",
+		},
+		{
+			name:     "password env not set",
+			connConf: RedisConnConfig{PasswordEnv: "TEST_REDIS_PASSWORD_NOT_SET"},
+			expected: "invalid redis password: environment variable 'TEST_REDIS_PASSWORD_NOT_SET' is not set",
+		},
+		{
+			name:     "negative db",
+			connConf: RedisConnConfig{DB: -1},
+			expected: "redis db must not be negative",
+		},
+		{
+			name:     "negative timeout",
+			connConf: RedisConnConfig{ReadTimeout: -time.Second},
+			expected: "redis timeouts must not be negative",
+		},
+		{
+			name:     "negative pool size",
+			connConf: RedisConnConfig{PoolSize: -1},
+			expected: "redis pool_size must not be negative",
+		},
+		{
+			name:     "tls files without tls enabled",
+			connConf: RedisConnConfig{TLSCAFile: certFile},
+			expected: "redis tls_enabled must be true when tls files are set",
+		},
+		{
+			name:     "tls cert without key",
+			connConf: RedisConnConfig{TLSEnabled: true, TLSCertFile: certFile},
+			expected: "redis tls_cert_file and tls_key_file must be set together",
+		},
+		{
+			name:     "tls invalid key",
+			connConf: RedisConnConfig{TLSEnabled: true, TLSCertFile: certFile, TLSKeyFile: certFile},
+			expected: "invalid redis tls config: " +
+				"tls: found a certificate rather than a key in the PEM for the private key",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newConfig(tc.connConf)
+			assert.PanicsWithValue(t, tc.expected, func() {
+				c.validateConfig()
+			})
+		})
+	}
+
+	t.Run("sentinel password", func(t *testing.T) {
+		c := Config{
+			ClientType: ClientTypeRedis,
+			RedisServers: []RedisConfig{
+				{
+					ID:                   11,
+					Type:                 RedisServerTypeSentinel,
+					SentinelMasterName:   "master01",
+					SentinelAddrs:        []string{"localhost:26379"},
+					SentinelPassword:     "pass01",
+					SentinelPasswordFile: "./password",
+				},
+			},
+		}
+		assert.PanicsWithValue(t,
+			"only one of redis sentinel_password, sentinel_password_env and sentinel_password_file can be set",
+			func() {
+				c.validateConfig()
+			},
+		)
+
+		c.RedisServers[0].SentinelPassword = ""
+		c.RedisServers[0].SentinelPasswordFile = ""
+		c.RedisServers[0].SentinelPasswordEnv = "TEST_SENTINEL_PASSWORD_NOT_SET"
+		assert.PanicsWithValue(t,
+			"invalid redis sentinel password: environment variable 'TEST_SENTINEL_PASSWORD_NOT_SET' is not set",
+			func() {
+				c.validateConfig()
+			},
+		)
+
+		c.RedisServers[0].SentinelUsername = "user01"
+		c.RedisServers[0].SentinelPasswordEnv = ""
+		c.RedisServers[0].SentinelPasswordFile = writeFile(t, "sentinel_password", []byte("pass02"))
+		assert.NotPanics(t, func() {
+			c.validateConfig()
+		})
+	})
+
+	t.Run("cluster db", func(t *testing.T) {
+		c := newConfig(RedisConnConfig{DB: 1})
+		assert.NotPanics(t, func() {
+			c.validateConfig()
+		})
+
+		c.RedisServers[0].Type = RedisServerTypeCluster
+		c.RedisServers[0].ClusterAddrs = []string{"localhost:7000"}
+		assert.PanicsWithValue(t, "redis cluster only supports db 0", func() {
+			c.validateConfig()
+		})
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		c := newConfig(RedisConnConfig{
+			Username:     "user01",
+			PasswordFile: writeFile(t, "password", []byte("pass01")),
+			DB:           2,
+
+			TLSEnabled:  true,
+			TLSCAFile:   certFile,
+			TLSCertFile: certFile,
+			TLSKeyFile:  keyFile,
+
+			DialTimeout: 5 * time.Second,
+			PoolSize:    10,
+		})
+		assert.NotPanics(t, func() {
+			c.validateConfig()
+		})
+	})
+}
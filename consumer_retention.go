@@ -0,0 +1,177 @@
+package cacheinv
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var retentionBlockedByConsumers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "retention_blocked_by_consumers",
+	Help: "equals to 1 when the retention is blocked by the consumers not consumed the old events",
+}, []string{"pipeline"})
+
+var retentionForcedDeletedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "retention_forced_deleted_events_total",
+	Help: "number of events not consumed by the cache server but deleted because of the retention hard cap",
+}, []string{"pipeline", "server_name"})
+
+// errRetentionBlocked is returned when the deleted events are limited by the consumers,
+// for making the retention jobs check again after their error retry durations
+var errRetentionBlocked = errors.New("cacheinv: retention blocked by lagging consumers")
+
+// consumerAwareRetention wraps the Repository for the retention jobs,
+// to not delete events that are not consumed by all cache servers,
+// unless the number of remaining events exceeds the hard cap
+type consumerAwareRetention struct {
+	repo        Repository
+	serverNames []string
+	hardCap     uint64
+	metrics     *jobMetrics
+}
+
+func newConsumerAwareRetention(
+	repo Repository, client Client, hardCap uint64, metrics *jobMetrics,
+) *consumerAwareRetention {
+	serverIDs := client.GetServerIDs()
+	serverNames := make([]string, 0, len(serverIDs))
+	for _, id := range serverIDs {
+		serverNames = append(serverNames, client.GetServerName(id))
+	}
+
+	return &consumerAwareRetention{
+		repo:        repo,
+		serverNames: serverNames,
+		hardCap:     hardCap,
+		metrics:     metrics,
+	}
+}
+
+// GetMinSequence returns the min sequence number of all events (except events with null sequence numbers)
+func (r *consumerAwareRetention) GetMinSequence(ctx context.Context) (sql.NullInt64, error) {
+	return r.repo.GetMinSequence(ctx)
+}
+
+// DeleteEventsBefore deletes events with sequence number < *beforeSeq*,
+// but not after the last sequence numbers of the consumers
+func (r *consumerAwareRetention) DeleteEventsBefore(ctx context.Context, beforeSeq uint64) error {
+	offsets, err := r.getOffsets(ctx)
+	if err != nil {
+		return err
+	}
+
+	allowedSeq := beforeSeq
+	for _, lastSeq := range offsets {
+		if lastSeq+1 < allowedSeq {
+			allowedSeq = lastSeq + 1
+		}
+	}
+
+	if allowedSeq < beforeSeq && r.hardCap > 0 {
+		allowedSeq, err = r.applyHardCap(ctx, offsets, allowedSeq, beforeSeq)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = r.repo.DeleteEventsBefore(ctx, allowedSeq)
+	if err != nil {
+		return err
+	}
+
+	if allowedSeq < beforeSeq {
+		r.metrics.retentionBlockedByConsumers.Set(1)
+		return errRetentionBlocked
+	}
+	r.metrics.retentionBlockedByConsumers.Set(0)
+	return nil
+}
+
+// getOffsets returns the last sequence numbers of the consumers, except consumers without offsets,
+// because they start from the last event
+func (r *consumerAwareRetention) getOffsets(ctx context.Context) (map[string]uint64, error) {
+	offsets := map[string]uint64{}
+	for _, name := range r.serverNames {
+		lastSeq, err := r.repo.GetLastSequence(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if lastSeq.Valid {
+			offsets[name] = uint64(lastSeq.Int64)
+		}
+	}
+	return offsets, nil
+}
+
+// applyHardCap returns the sequence number that events before it can be deleted,
+// increased from *allowedSeq* (but not exceeding *beforeSeq*) when the hard cap exceeded
+func (r *consumerAwareRetention) applyHardCap(
+	ctx context.Context, offsets map[string]uint64, allowedSeq uint64, beforeSeq uint64,
+) (uint64, error) {
+	capSeq, err := r.hardCapSequence(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if capSeq <= allowedSeq {
+		return allowedSeq, nil
+	}
+
+	if capSeq > beforeSeq {
+		capSeq = beforeSeq
+	}
+
+	err = r.reportForcedDeletions(ctx, offsets, capSeq)
+	if err != nil {
+		return 0, err
+	}
+	return capSeq, nil
+}
+
+// hardCapSequence returns the sequence number that events before it must be deleted to satisfy the hard cap
+func (r *consumerAwareRetention) hardCapSequence(ctx context.Context) (uint64, error) {
+	events, err := r.repo.GetLastEvents(ctx, 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	nextSeq := events[0].GetSequence() + 1
+	if nextSeq <= r.hardCap {
+		return 0, nil
+	}
+	return nextSeq - r.hardCap, nil
+}
+
+// reportForcedDeletions logs and counts the events in range [min sequence, *beforeSeq*)
+// that are going to be deleted but not consumed by the cache servers
+func (r *consumerAwareRetention) reportForcedDeletions(
+	ctx context.Context, offsets map[string]uint64, beforeSeq uint64,
+) error {
+	minSeq, err := r.repo.GetMinSequence(ctx)
+	if err != nil {
+		return err
+	}
+
+	for name, lastSeq := range offsets {
+		fromSeq := lastSeq + 1
+		if uint64(minSeq.Int64) > fromSeq {
+			fromSeq = uint64(minSeq.Int64)
+		}
+		if fromSeq >= beforeSeq {
+			continue
+		}
+
+		log.Printf(
+			"[ERROR] retention hard cap exceeded, deleting %d events not consumed by server '%s' (last_seq = %d)\n",
+			beforeSeq-fromSeq, name, lastSeq,
+		)
+		r.metrics.retentionForcedDeletedEvents.WithLabelValues(name).Add(float64(beforeSeq - fromSeq))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package cacheinv
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var doubleDeleteScheduledKeysTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "double_delete_scheduled_keys_total",
+	Help: "number of keys scheduled for the delayed second delete",
+}, []string{"pipeline", "server_name"})
+
+var doubleDeleteExecutedKeysTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "double_delete_executed_keys_total",
+	Help: "number of keys deleted by the delayed second delete",
+}, []string{"pipeline", "server_name"})
+
+var doubleDeleteDroppedKeysTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "double_delete_dropped_keys_total",
+	Help: "number of keys dropped from the delayed second delete, because of the pending limit or errors",
+}, []string{"pipeline", "server_name"})
+
+var doubleDeletePendingKeys = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "double_delete_pending_keys",
+	Help: "number of keys waiting for the delayed second delete",
+}, []string{"pipeline", "server_name"})
+
+type delayedDelete struct {
+	deadline time.Time
+	keys     []string
+}
+
+// doubleDeleter keeps the delayed deletes of a cache server in memory.
+// When the number of pending keys exceeds the limit, the oldest delayed deletes are dropped.
+// A batch of keys larger than the limit is truncated to the limit
+type doubleDeleter struct {
+	delay          time.Duration
+	maxPendingKeys int
+	serverName     string
+	deleteFunc     func(ctx context.Context, keys []string) error
+	metrics        *jobMetrics
+
+	signal chan struct{}
+
+	mut sync.Mutex
+	// queue[head:] are the delayed deletes, ordered by deadlines
+	queue       []delayedDelete
+	head        int
+	pendingKeys int
+}
+
+func newDoubleDeleter(
+	delay time.Duration, maxPendingKeys int, serverName string,
+	deleteFunc func(ctx context.Context, keys []string) error, metrics *jobMetrics,
+) *doubleDeleter {
+	return &doubleDeleter{
+		delay:          delay,
+		maxPendingKeys: maxPendingKeys,
+		serverName:     serverName,
+		deleteFunc:     deleteFunc,
+		metrics:        metrics,
+
+		signal: make(chan struct{}, 1),
+	}
+}
+
+func (d *doubleDeleter) schedule(keys []string) {
+	d.mut.Lock()
+
+	d.metrics.doubleDeleteScheduled.WithLabelValues(d.serverName).Add(float64(len(keys)))
+	if len(keys) > d.maxPendingKeys {
+		log.Printf(
+			"[WARN] double delete on server '%s': dropped %d keys, the batch of %d keys exceeds the pending limit\n",
+			d.serverName, len(keys)-d.maxPendingKeys, len(keys),
+		)
+		d.metrics.doubleDeleteDropped.WithLabelValues(d.serverName).Add(float64(len(keys) - d.maxPendingKeys))
+		keys = keys[:d.maxPendingKeys]
+	}
+
+	d.queue = append(d.queue, delayedDelete{
+		deadline: time.Now().Add(d.delay),
+		keys:     keys,
+	})
+	d.pendingKeys += len(keys)
+
+	for d.pendingKeys > d.maxPendingKeys {
+		dropped := d.popFront()
+		d.pendingKeys -= len(dropped.keys)
+		d.metrics.doubleDeleteDropped.WithLabelValues(d.serverName).Add(float64(len(dropped.keys)))
+	}
+	d.metrics.doubleDeletePendingKeys.WithLabelValues(d.serverName).Set(float64(d.pendingKeys))
+
+	d.mut.Unlock()
+
+	select {
+	case d.signal <- struct{}{}:
+	default:
+	}
+}
+
+// popDueKeys returns the keys of the delayed deletes that have reached the deadlines,
+// and the waiting duration for the next delayed delete (= 0 if the queue is empty)
+func (d *doubleDeleter) popDueKeys(now time.Time) ([]string, time.Duration) {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	var keys []string
+	for d.head < len(d.queue) {
+		if d.queue[d.head].deadline.After(now) {
+			break
+		}
+		keys = append(keys, d.popFront().keys...)
+	}
+
+	d.pendingKeys -= len(keys)
+	d.metrics.doubleDeletePendingKeys.WithLabelValues(d.serverName).Set(float64(d.pendingKeys))
+
+	if d.head == len(d.queue) {
+		return keys, 0
+	}
+	return keys, d.queue[d.head].deadline.Sub(now)
+}
+
+// popFront removes the oldest delayed delete, the queue is compacted when half of it was removed,
+// so the removed delayed deletes are not referenced by the backing array
+func (d *doubleDeleter) popFront() delayedDelete {
+	first := d.queue[d.head]
+	d.queue[d.head] = delayedDelete{}
+	d.head++
+
+	if d.head*2 >= len(d.queue) {
+		n := copy(d.queue, d.queue[d.head:])
+		for i := n; i < len(d.queue); i++ {
+			d.queue[i] = delayedDelete{}
+		}
+		d.queue = d.queue[:n]
+		d.head = 0
+	}
+	return first
+}
+
+func (d *doubleDeleter) run(ctx context.Context) {
+	for {
+		keys, waitDuration := d.popDueKeys(time.Now())
+
+		if len(keys) > 0 {
+			err := d.deleteFunc(ctx, keys)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("[ERROR] double delete on server '%s': %v\n", d.serverName, err)
+				d.metrics.errorTotal.WithLabelValues("double_delete").Add(1)
+				d.metrics.doubleDeleteDropped.WithLabelValues(d.serverName).Add(float64(len(keys)))
+			} else {
+				d.metrics.doubleDeleteExecuted.WithLabelValues(d.serverName).Add(float64(len(keys)))
+			}
+			continue
+		}
+
+		if waitDuration == 0 {
+			select {
+			case <-d.signal:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-time.After(waitDuration):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
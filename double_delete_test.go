@@ -0,0 +1,181 @@
+package cacheinv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type doubleDeleterTest struct {
+	deleter *doubleDeleter
+
+	mut     sync.Mutex
+	deleted [][]string
+	err     error
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+}
+
+func newDoubleDeleterTest(delay time.Duration, maxPendingKeys int) *doubleDeleterTest {
+	d := &doubleDeleterTest{}
+	d.deleter = newDoubleDeleter(delay, maxPendingKeys, "redis:11", func(ctx context.Context, keys []string) error {
+		d.mut.Lock()
+		defer d.mut.Unlock()
+		d.deleted = append(d.deleted, keys)
+		return d.err
+	}, newJobMetrics(DefaultPipelineName))
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	return d
+}
+
+func (d *doubleDeleterTest) run() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deleter.run(d.ctx)
+	}()
+}
+
+func (d *doubleDeleterTest) shutdown() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *doubleDeleterTest) getDeleted() [][]string {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.deleted
+}
+
+func TestDoubleDeleter(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		d := newDoubleDeleterTest(100*time.Millisecond, 100)
+		d.run()
+
+		d.deleter.schedule([]string{"key01", "key02"})
+		d.deleter.schedule([]string{"key03"})
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, 0, len(d.getDeleted()))
+
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, [][]string{
+			{"key01", "key02", "key03"},
+		}, d.getDeleted())
+
+		d.deleter.schedule([]string{"key04"})
+
+		time.Sleep(150 * time.Millisecond)
+		assert.Equal(t, [][]string{
+			{"key01", "key02", "key03"},
+			{"key04"},
+		}, d.getDeleted())
+
+		d.shutdown()
+
+		assert.Equal(t, 0, d.deleter.pendingKeys)
+	})
+
+	t.Run("drop oldest when exceeding limit", func(t *testing.T) {
+		d := newDoubleDeleterTest(50*time.Millisecond, 3)
+
+		d.deleter.schedule([]string{"key01", "key02"})
+		d.deleter.schedule([]string{"key03"})
+		d.deleter.schedule([]string{"key04"})
+
+		assert.Equal(t, 2, d.deleter.pendingKeys)
+
+		d.run()
+		time.Sleep(100 * time.Millisecond)
+		d.shutdown()
+
+		assert.Equal(t, [][]string{
+			{"key03", "key04"},
+		}, d.getDeleted())
+	})
+
+	t.Run("truncate batch exceeding limit", func(t *testing.T) {
+		d := newDoubleDeleterTest(50*time.Millisecond, 3)
+
+		d.deleter.schedule([]string{"key01"})
+		d.deleter.schedule([]string{"key02", "key03", "key04", "key05"})
+
+		assert.Equal(t, 3, d.deleter.pendingKeys)
+
+		d.run()
+		time.Sleep(100 * time.Millisecond)
+		d.shutdown()
+
+		assert.Equal(t, [][]string{
+			{"key02", "key03", "key04"},
+		}, d.getDeleted())
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		d := newDoubleDeleterTest(20*time.Millisecond, 100)
+		d.err = errors.New("delete error")
+
+		d.run()
+
+		d.deleter.schedule([]string{"key01"})
+
+		time.Sleep(100 * time.Millisecond)
+		d.shutdown()
+
+		assert.Equal(t, [][]string{
+			{"key01"},
+		}, d.getDeleted())
+		assert.Equal(t, 0, d.deleter.pendingKeys)
+	})
+
+	t.Run("pop due keys", func(t *testing.T) {
+		d := newDoubleDeleterTest(100*time.Millisecond, 100)
+
+		keys, waitDuration := d.deleter.popDueKeys(time.Now())
+		assert.Equal(t, 0, len(keys))
+		assert.Equal(t, time.Duration(0), waitDuration)
+
+		d.deleter.schedule([]string{"key01"})
+
+		keys, waitDuration = d.deleter.popDueKeys(time.Now())
+		assert.Equal(t, 0, len(keys))
+		assert.Greater(t, waitDuration, 50*time.Millisecond)
+
+		keys, waitDuration = d.deleter.popDueKeys(time.Now().Add(100 * time.Millisecond))
+		assert.Equal(t, []string{"key01"}, keys)
+		assert.Equal(t, time.Duration(0), waitDuration)
+	})
+
+	t.Run("queue is compacted", func(t *testing.T) {
+		d := newDoubleDeleterTest(100*time.Millisecond, 1000)
+
+		for i := 0; i < 100; i++ {
+			d.deleter.schedule([]string{fmt.Sprintf("key%02d", i)})
+		}
+		var popped []string
+		for d.deleter.head < len(d.deleter.queue) {
+			keys, _ := d.deleter.popDueKeys(d.deleter.queue[d.deleter.head].deadline)
+			popped = append(popped, keys...)
+
+			assert.LessOrEqual(t, d.deleter.head*2, len(d.deleter.queue))
+			for _, removed := range d.deleter.queue[:d.deleter.head] {
+				assert.Equal(t, delayedDelete{}, removed)
+			}
+		}
+
+		assert.Equal(t, 100, len(popped))
+		assert.Equal(t, "key00", popped[0])
+		assert.Equal(t, "key99", popped[99])
+
+		assert.Equal(t, 0, len(d.deleter.queue))
+		assert.Equal(t, 0, d.deleter.head)
+		assert.Equal(t, 0, d.deleter.pendingKeys)
+	})
+}
@@ -0,0 +1,213 @@
+package cacheinv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Operation is the type of invalidation described by an event
+type Operation string
+
+const (
+	// OperationDelete deletes the cache keys
+	OperationDelete Operation = "delete"
+
+	// OperationPattern deletes all cache keys matching the glob-style patterns in EventPayload.Keys,
+	// requires the client to implement PatternClient
+	OperationPattern Operation = "pattern"
+
+	// OperationTag deletes all cache keys in the tag sets named by EventPayload.Keys, along with the tag sets,
+	// requires the client to implement TagClient
+	OperationTag Operation = "tag"
+
+	// OperationVersionedDelete deletes the cache keys only if the versions of the cached values
+	// are lower than EventPayload.KeyVersion, requires the client to implement VersionedClient
+	OperationVersionedDelete Operation = "versioned_delete"
+
+	// OperationExpire sets short TTLs for the cache keys instead of deleting them,
+	// requires the client to implement ExpireClient
+	OperationExpire Operation = "expire"
+)
+
+// PayloadVersion is the current version of the JSON format of InvalidateEvent.Data
+const PayloadVersion = 1
+
+// EventPayload is the decoded form of InvalidateEvent.Data.
+//
+// InvalidateEvent.Data can be in one of two formats:
+//   - plain format: comma-separated list of cache keys, e.g. "key01,key02", see EncodeKeys for escaping
+//   - JSON format: a JSON object with the version field "v", e.g. {"v":1,"op":"delete","keys":["key01"]}
+//
+// Data starting with '{"v"' is always in the JSON format, invalid JSON is an error.
+// Other data starting with '{' but not a JSON object with "v" is in the plain format,
+// e.g. the redis keys with hash tags "{user:42}:profile,{user:42}:settings"
+type EventPayload struct {
+	Version int       `json:"v"`
+	Op      Operation `json:"op,omitempty"`
+	Keys    []string  `json:"keys"`
+
+	// KeyVersion is the version of the keys, only used by OperationVersionedDelete
+	KeyVersion uint64 `json:"version,omitempty"`
+
+	// TTL is in seconds, only used by OperationExpire, the default TTL (WithExpireTTL) is used if = 0
+	TTL uint32 `json:"ttl,omitempty"`
+
+	// Servers is the list of server names (Client.GetServerName) the event applies to
+	Servers []string `json:"servers,omitempty"`
+
+	// Groups is the list of server groups (WithServerGroup) the event applies to.
+	// The event applies to all servers if both Servers and Groups are empty
+	Groups []string `json:"groups,omitempty"`
+
+	// Meta is not used by the invalidator job, only for logging / debugging purposes
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// ErrInvalidPayload is returned when InvalidateEvent.Data can not be decoded
+var ErrInvalidPayload = errors.New("cacheinv: invalid event payload")
+
+// isJSONPayload returns true if the data is a JSON object having the version field "v".
+// Data starting with the field "v" is always in the JSON format, even if it is invalid JSON
+func isJSONPayload(data string) bool {
+	if !strings.HasPrefix(data, "{") {
+		return false
+	}
+	if strings.HasPrefix(strings.TrimLeft(data[1:], " \t\r\n"), `"v"`) {
+		return true
+	}
+
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal([]byte(data), &fields)
+	if err != nil {
+		return false
+	}
+	_, ok := fields["v"]
+	return ok
+}
+
+// EncodeKeys encodes cache keys into the plain format of InvalidateEvent.Data.
+//
+// Inside a key, a backslash is escaped as '\\' and a comma is escaped as '\,'.
+// A '{' at the beginning of the data is escaped as '\{' to not be confused with the JSON format.
+func EncodeKeys(keys []string) string {
+	var buf []byte
+	for i, key := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		for k := 0; k < len(key); k++ {
+			ch := key[k]
+			if ch == '\\' || ch == ',' || (ch == '{' && len(buf) == 0) {
+				buf = append(buf, '\\')
+			}
+			buf = append(buf, ch)
+		}
+	}
+	return string(buf)
+}
+
+// splitKeys splits the plain format into cache keys. A backslash only escapes the characters escaped by EncodeKeys:
+// a comma, a backslash, or a '{' at the beginning of the data, otherwise it is a normal character
+func splitKeys(data string) []string {
+	if !strings.Contains(data, "\\") {
+		return strings.Split(data, ",")
+	}
+
+	var keys []string
+	var current []byte
+	for i := 0; i < len(data); i++ {
+		ch := data[i]
+		switch {
+		case ch == '\\' && i+1 < len(data) && isEscapedChar(data[i+1], i+1):
+			i++
+			current = append(current, data[i])
+		case ch == ',':
+			keys = append(keys, string(current))
+			current = current[:0]
+		default:
+			current = append(current, ch)
+		}
+	}
+	return append(keys, string(current))
+}
+
+func isEscapedChar(ch byte, index int) bool {
+	return ch == ',' || ch == '\\' || (ch == '{' && index == 1)
+}
+
+// ParseEventData decodes InvalidateEvent.Data in either plain or JSON format
+func ParseEventData(data string) (EventPayload, error) {
+	return ParseEventDataWithDefault(data, OperationDelete)
+}
+
+// ParseEventDataWithDefault is similar to ParseEventData, but uses *defaultOp* as the operation
+// for the plain format and the JSON format without "op"
+func ParseEventDataWithDefault(data string, defaultOp Operation) (EventPayload, error) {
+	if !isJSONPayload(data) {
+		return EventPayload{
+			Op:   defaultOp,
+			Keys: splitKeys(data),
+		}, nil
+	}
+
+	var payload EventPayload
+	err := json.Unmarshal([]byte(data), &payload)
+	if err != nil {
+		return EventPayload{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	if payload.Version != PayloadVersion {
+		return EventPayload{}, fmt.Errorf("%w: unsupported version '%d'", ErrInvalidPayload, payload.Version)
+	}
+
+	if len(payload.Op) == 0 {
+		payload.Op = defaultOp
+	}
+
+	switch payload.Op {
+	case OperationDelete, OperationPattern, OperationTag, OperationExpire:
+	case OperationVersionedDelete:
+		if payload.KeyVersion == 0 {
+			return EventPayload{}, fmt.Errorf("%w: missing version for '%s'", ErrInvalidPayload, payload.Op)
+		}
+	default:
+		return EventPayload{}, fmt.Errorf("%w: unsupported operation '%s'", ErrInvalidPayload, payload.Op)
+	}
+
+	return payload, nil
+}
+
+// EncodeEventPayload encodes the payload into JSON format for InvalidateEvent.Data
+func EncodeEventPayload(payload EventPayload) (string, error) {
+	if payload.Version == 0 {
+		payload.Version = PayloadVersion
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// AppliesTo returns true if the event should be applied to the server with *serverName*
+// and belongs to the server groups *serverGroups*
+func (p EventPayload) AppliesTo(serverName string, serverGroups []string) bool {
+	if len(p.Servers) == 0 && len(p.Groups) == 0 {
+		return true
+	}
+	for _, name := range p.Servers {
+		if name == serverName {
+			return true
+		}
+	}
+	for _, group := range p.Groups {
+		for _, serverGroup := range serverGroups {
+			if group == serverGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
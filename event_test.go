@@ -0,0 +1,297 @@
+package cacheinv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEventData(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		payload, err := ParseEventData("key01,key02")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{"key01", "key02"},
+		}, payload)
+	})
+
+	t.Run("plain single key", func(t *testing.T) {
+		payload, err := ParseEventData("key01")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{"key01"},
+		}, payload)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		payload, err := ParseEventData(
+			`{"v":1,"op":"delete","keys":["key01","key,02"],"servers":["redis:11"],"groups":["tenant01"],` +
+				`"meta":{"source":"app01"}}`,
+		)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version: 1,
+			Op:      OperationDelete,
+			Keys:    []string{"key01", "key,02"},
+			Servers: []string{"redis:11"},
+			Groups:  []string{"tenant01"},
+			Meta: map[string]string{
+				"source": "app01",
+			},
+		}, payload)
+	})
+
+	t.Run("json default operation", func(t *testing.T) {
+		payload, err := ParseEventData(`{"v":1,"keys":["key01"]}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version: 1,
+			Op:      OperationDelete,
+			Keys:    []string{"key01"},
+		}, payload)
+	})
+
+	t.Run("json pattern", func(t *testing.T) {
+		payload, err := ParseEventData(`{"v":1,"op":"pattern","keys":["user:42:*"]}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version: 1,
+			Op:      OperationPattern,
+			Keys:    []string{"user:42:*"},
+		}, payload)
+	})
+
+	t.Run("json tag", func(t *testing.T) {
+		payload, err := ParseEventData(`{"v":1,"op":"tag","keys":["tag:product:99"]}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version: 1,
+			Op:      OperationTag,
+			Keys:    []string{"tag:product:99"},
+		}, payload)
+	})
+
+	t.Run("json versioned delete", func(t *testing.T) {
+		payload, err := ParseEventData(`{"v":1,"op":"versioned_delete","keys":["key01"],"version":12}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version:    1,
+			Op:         OperationVersionedDelete,
+			Keys:       []string{"key01"},
+			KeyVersion: 12,
+		}, payload)
+	})
+
+	t.Run("json versioned delete missing version", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":1,"op":"versioned_delete","keys":["key01"]}`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+		assert.Equal(t, "cacheinv: invalid event payload: missing version for 'versioned_delete'", err.Error())
+	})
+
+	t.Run("json expire", func(t *testing.T) {
+		payload, err := ParseEventData(`{"v":1,"op":"expire","keys":["key01"],"ttl":5}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Version: 1,
+			Op:      OperationExpire,
+			Keys:    []string{"key01"},
+			TTL:     5,
+		}, payload)
+	})
+
+	t.Run("json invalid", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":1,`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+	})
+
+	t.Run("json truncated", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":1,"keys":["a"`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+
+		_, err = ParseEventData("{ \n\t\"v\": 1, \"keys\"")
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+	})
+
+	t.Run("invalid json without version is plain", func(t *testing.T) {
+		payload, err := ParseEventData(`{"keys":["a"`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{`{"keys":["a"`},
+		}, payload)
+	})
+
+	t.Run("plain with hash tags", func(t *testing.T) {
+		payload, err := ParseEventData("{user:42}:profile,{user:42}:settings")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{"{user:42}:profile", "{user:42}:settings"},
+		}, payload)
+	})
+
+	t.Run("plain single hash tag key", func(t *testing.T) {
+		payload, err := ParseEventData("{user:42}")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{"{user:42}"},
+		}, payload)
+	})
+
+	t.Run("json object without version is plain", func(t *testing.T) {
+		payload, err := ParseEventData(`{"keys":["key01"]}`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, EventPayload{
+			Op:   OperationDelete,
+			Keys: []string{`{"keys":["key01"]}`},
+		}, payload)
+	})
+
+	t.Run("json invalid field", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":1,"keys":"key01"}`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+	})
+
+	t.Run("json unsupported version", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":2,"keys":["key01"]}`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+		assert.Equal(t, "cacheinv: invalid event payload: unsupported version '2'", err.Error())
+	})
+
+	t.Run("json unsupported operation", func(t *testing.T) {
+		_, err := ParseEventData(`{"v":1,"op":"another","keys":["key01"]}`)
+		assert.Equal(t, true, errors.Is(err, ErrInvalidPayload))
+		assert.Equal(t, "cacheinv: invalid event payload: unsupported operation 'another'", err.Error())
+	})
+}
+
+func TestParseEventDataWithDefault(t *testing.T) {
+	payload, err := ParseEventDataWithDefault("key01,key02", OperationExpire)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, EventPayload{
+		Op:   OperationExpire,
+		Keys: []string{"key01", "key02"},
+	}, payload)
+
+	payload, err = ParseEventDataWithDefault(`{"v":1,"keys":["key01"]}`, OperationExpire)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, EventPayload{
+		Version: 1,
+		Op:      OperationExpire,
+		Keys:    []string{"key01"},
+	}, payload)
+
+	payload, err = ParseEventDataWithDefault(`{"v":1,"op":"delete","keys":["key01"]}`, OperationExpire)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, EventPayload{
+		Version: 1,
+		Op:      OperationDelete,
+		Keys:    []string{"key01"},
+	}, payload)
+}
+
+func TestEncodeEventPayload(t *testing.T) {
+	data, err := EncodeEventPayload(EventPayload{
+		Keys:    []string{"key01", "key02"},
+		Servers: []string{"redis:11"},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"v":1,"keys":["key01","key02"],"servers":["redis:11"]}`, data)
+
+	payload, err := ParseEventData(data)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, EventPayload{
+		Version: 1,
+		Op:      OperationDelete,
+		Keys:    []string{"key01", "key02"},
+		Servers: []string{"redis:11"},
+	}, payload)
+}
+
+func TestEventPayload_AppliesTo(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		p := EventPayload{}
+		assert.Equal(t, true, p.AppliesTo("redis:11", nil))
+		assert.Equal(t, true, p.AppliesTo("redis:11", []string{"group01"}))
+	})
+
+	t.Run("servers", func(t *testing.T) {
+		p := EventPayload{Servers: []string{"redis:11", "redis:13"}}
+		assert.Equal(t, true, p.AppliesTo("redis:11", nil))
+		assert.Equal(t, false, p.AppliesTo("redis:12", nil))
+		assert.Equal(t, true, p.AppliesTo("redis:13", nil))
+	})
+
+	t.Run("groups", func(t *testing.T) {
+		p := EventPayload{Groups: []string{"group01", "group02"}}
+		assert.Equal(t, false, p.AppliesTo("redis:11", nil))
+		assert.Equal(t, true, p.AppliesTo("redis:11", []string{"group02"}))
+		assert.Equal(t, false, p.AppliesTo("redis:11", []string{"group03"}))
+		assert.Equal(t, true, p.AppliesTo("redis:11", []string{"group03", "group01"}))
+	})
+
+	t.Run("servers and groups", func(t *testing.T) {
+		p := EventPayload{Servers: []string{"redis:11"}, Groups: []string{"group01"}}
+		assert.Equal(t, true, p.AppliesTo("redis:11", nil))
+		assert.Equal(t, true, p.AppliesTo("redis:12", []string{"group01"}))
+		assert.Equal(t, false, p.AppliesTo("redis:12", []string{"group02"}))
+	})
+}
+
+func TestEncodeKeys(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		assert.Equal(t, "key01,key02", EncodeKeys([]string{"key01", "key02"}))
+	})
+
+	t.Run("with escape", func(t *testing.T) {
+		keys := []string{`search:q=a,b`, `path\a`, `{data}`, ``, `a\,`}
+		data := EncodeKeys(keys)
+		assert.Equal(t, `search:q=a\,b,path\\a,{data},,a\\\,`, data)
+
+		payload, err := ParseEventData(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, keys, payload.Keys)
+	})
+
+	t.Run("begin with open brace", func(t *testing.T) {
+		keys := []string{`{user}:1`, `key01`}
+		data := EncodeKeys(keys)
+		assert.Equal(t, `\{user}:1,key01`, data)
+
+		payload, err := ParseEventData(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, keys, payload.Keys)
+	})
+}
+
+func TestSplitKeys(t *testing.T) {
+	assert.Equal(t, []string{"key01", "key02"}, splitKeys("key01,key02"))
+	assert.Equal(t, []string{""}, splitKeys(""))
+	assert.Equal(t, []string{"a,b", "c"}, splitKeys(`a\,b,c`))
+	assert.Equal(t, []string{`a\b`, "c"}, splitKeys(`a\b,c`))
+	assert.Equal(t, []string{`a\`}, splitKeys(`a\`))
+	assert.Equal(t, []string{`a\`, ""}, splitKeys(`a\\,`))
+	assert.Equal(t, []string{"{a}", `b\{c}`}, splitKeys(`\{a},b\{c}`))
+}
+
+func TestSplitKeys_LegacyBackslash(t *testing.T) {
+	// data written before the escaping was introduced, the backslashes are not followed by escaped characters
+	legacy := []string{
+		`a\b,c`,
+		`path\to\file,key02`,
+		`C:\data\{id},x`,
+		`key\n`,
+	}
+	for _, data := range legacy {
+		assert.Equal(t, strings.Split(data, ","), splitKeys(data), data)
+
+		payload, err := ParseEventData(data)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, strings.Split(data, ","), payload.Keys)
+	}
+}
@@ -0,0 +1,195 @@
+package cacheinv
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/QuangTung97/eventx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// GapRecoveryAction is the action run when the events after the offset of a cache server
+// were already deleted by the retention, see WithGapRecovery
+type GapRecoveryAction string
+
+const (
+	// GapRecoveryNone keeps the consumer retrying on the missing events, this is the default
+	GapRecoveryNone GapRecoveryAction = ""
+	// GapRecoveryAlert only logs the gap and counts it in the metric cache_consumer_gap_recoveries_total
+	GapRecoveryAlert GapRecoveryAction = "alert"
+	// GapRecoveryFlush removes all keys of the cache server, requires the Client implementing FlushClient
+	GapRecoveryFlush GapRecoveryAction = "flush"
+	// GapRecoveryWebhook sends a POST request with the ConsumerGap in JSON to the webhook url
+	GapRecoveryWebhook GapRecoveryAction = "webhook"
+)
+
+// FlushClient is an optional interface of Client for supporting GapRecoveryFlush
+type FlushClient interface {
+	// FlushCache removes all keys of the cache server
+	FlushCache(ctx context.Context, serverID int64) error
+}
+
+// ConsumerGap describes the missing events of a cache server,
+// events with sequence numbers in range (LastSeq, MinSeq) were deleted before being consumed
+type ConsumerGap struct {
+	ServerName string `json:"server_name"`
+	LastSeq    int64  `json:"last_seq"`
+	MinSeq     int64  `json:"min_seq"`
+}
+
+var cacheConsumerGapRecoveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_consumer_gap_recoveries_total",
+	Help: "number of times the cache server missed the deleted events and was recovered",
+}, []string{"pipeline", "server_name", "action"})
+
+type gapRecovery struct {
+	action     GapRecoveryAction
+	webhookURL string
+	httpClient *http.Client
+
+	flushClient FlushClient
+}
+
+func newGapRecovery(client Client, conf jobConfig) *gapRecovery {
+	r := &gapRecovery{
+		action:     conf.gapRecoveryAction,
+		webhookURL: conf.gapRecoveryWebhookURL,
+		httpClient: &http.Client{Timeout: conf.gapRecoveryWebhookTimeout},
+	}
+
+	switch conf.gapRecoveryAction {
+	case GapRecoveryNone:
+		return nil
+
+	case GapRecoveryAlert:
+
+	case GapRecoveryFlush:
+		flushClient, ok := client.(FlushClient)
+		if !ok {
+			panic("cacheinv: gap recovery flush is not supported by the client")
+		}
+		r.flushClient = flushClient
+
+	case GapRecoveryWebhook:
+		if len(conf.gapRecoveryWebhookURL) == 0 {
+			panic("cacheinv: gap recovery webhook url must not be empty")
+		}
+
+	default:
+		panic(fmt.Sprintf("cacheinv: invalid gap recovery action '%s'", conf.gapRecoveryAction))
+	}
+
+	return r
+}
+
+func (r *gapRecovery) recover(ctx context.Context, serverID int64, gap ConsumerGap) error {
+	switch r.action {
+	case GapRecoveryFlush:
+		return r.flushClient.FlushCache(ctx, serverID)
+	case GapRecoveryWebhook:
+		return r.callWebhook(ctx, gap)
+	default:
+		return nil
+	}
+}
+
+func (r *gapRecovery) callWebhook(ctx context.Context, gap ConsumerGap) error {
+	body, err := json.Marshal(gap)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cacheinv: gap recovery webhook returned status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// getLastSequence returns the offset of the cache server. If the events right after the offset
+// were already deleted, the gap recovery is run and the offset is moved to right before the min sequence number
+func (j *InvalidatorJob) getLastSequence(
+	ctx context.Context, serverID int64, serverName string,
+) (sql.NullInt64, error) {
+	lastSeq, err := j.repo.GetLastSequence(ctx, serverName)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	if !lastSeq.Valid || j.gapRecovery == nil {
+		return lastSeq, nil
+	}
+
+	minSeq, err := j.repo.GetMinSequence(ctx)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	if !minSeq.Valid || lastSeq.Int64+1 >= minSeq.Int64 {
+		return lastSeq, nil
+	}
+
+	gap := ConsumerGap{
+		ServerName: serverName,
+		LastSeq:    lastSeq.Int64,
+		MinSeq:     minSeq.Int64,
+	}
+	log.Printf(
+		"[ERROR] server '%s' missed the deleted events from seq = %d to %d, run gap recovery '%s'\n",
+		serverName, gap.LastSeq+1, gap.MinSeq-1, j.gapRecovery.action,
+	)
+
+	err = j.gapRecovery.recover(ctx, serverID, gap)
+	if err != nil {
+		return sql.NullInt64{}, fmt.Errorf("gap recovery: %w", err)
+	}
+
+	newSeq := minSeq.Int64 - 1
+	err = j.repo.SetLastSequence(ctx, serverName, newSeq)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+	j.metrics.consumerGapRecoveries.WithLabelValues(serverName, string(j.gapRecovery.action)).Inc()
+
+	return sql.NullInt64{Valid: true, Int64: newSeq}, nil
+}
+
+// gapRecoveryRetryOptions returns the options for restarting the consumer
+// when the subscriber can not find the events after the offset, the offset is checked again after restarting.
+// The errors are then passed to the logger of WithRetryConsumerErrorLogger
+func (j *InvalidatorJob) gapRecoveryRetryOptions(restart func()) []eventx.RetryConsumerOption {
+	logger := j.conf.retryErrorLogger
+	if logger == nil {
+		logger = j.logRetryConsumerError
+	}
+
+	return []eventx.RetryConsumerOption{
+		eventx.WithRetryConsumerErrorLogger(func(err error) {
+			if errors.Is(err, eventx.ErrEventNotFound) {
+				restart()
+			}
+			logger(err)
+		}),
+	}
+}
+
+func (j *InvalidatorJob) logRetryConsumerError(err error) {
+	log.Println("[ERROR] retry consumer:", err)
+	j.metrics.errorTotal.WithLabelValues("consumer").Add(1)
+}
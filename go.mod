@@ -8,7 +8,9 @@ require (
 	github.com/dustin/go-humanize v1.0.1
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/jmoiron/sqlx v1.3.5
+	github.com/lib/pq v1.10.9
 	github.com/matryer/moq v0.3.3
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/mgechev/revive v1.3.4
 	github.com/prometheus/client_golang v1.17.0
 	github.com/redis/go-redis/v9 v9.3.0
@@ -0,0 +1,19 @@
+// Package dbmetrics contains the metrics shared by the Repository implementations
+package dbmetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// EventLastUpdatedSeq ...
+var EventLastUpdatedSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "event_last_updated_seq",
+	Help: "sequence number of the last updated invalidate_events",
+}, []string{"pipeline"})
+
+// EventMinRemainingSeq ...
+var EventMinRemainingSeq = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "event_min_remaining_seq",
+	Help: "smallest sequence number after retention",
+}, []string{"pipeline"})
@@ -0,0 +1,252 @@
+// Package sqlrepo contains the Repository implementation shared by the postgres and sqlite packages,
+// the queries only differ by the Dialect of the databases
+package sqlrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/internal/dbmetrics"
+)
+
+// Dialect is the database specific parts of the queries, the queries are written with '?' placeholders
+// and rebound using BindType
+type Dialect struct {
+	// BindType is the placeholder type of the database, e.g. sqlx.DOLLAR
+	BindType int
+
+	// Now is the SQL expression of the current timestamp
+	Now string
+
+	// CreatedBefore returns the condition selecting events created more than *maxAge* ago, with its argument
+	CreatedBefore func(maxAge time.Duration) (cond string, arg any)
+
+	// UpdateSequences updates the sequence numbers (and updated_at) of the *events* with null sequence numbers
+	// in the transaction *tx*, returns the number of updated events
+	UpdateSequences func(ctx context.Context, tx *sqlx.Tx, eventTableName string, events []cacheinv.InvalidateEvent) (
+		int64, error,
+	)
+
+	// ErrSequenceAlreadyUpdated is returned when some of the events not existed or already have sequence numbers
+	ErrSequenceAlreadyUpdated error
+}
+
+// Repository ...
+type Repository struct {
+	dialect      Dialect
+	pipelineName string
+
+	db              *sqlx.DB
+	eventTableName  string
+	offsetTableName string
+}
+
+var _ cacheinv.Repository = &Repository{}
+var _ cacheinv.AgeRetentionRepository = &Repository{}
+
+// NewRepository ...
+func NewRepository(
+	db *sqlx.DB,
+	dialect Dialect,
+	eventTableName string,
+	offsetTableName string,
+	pipelineName string,
+) *Repository {
+	return &Repository{
+		dialect:      dialect,
+		pipelineName: pipelineName,
+
+		db:              db,
+		eventTableName:  eventTableName,
+		offsetTableName: offsetTableName,
+	}
+}
+
+func (r *Repository) rebind(query string) string {
+	return sqlx.Rebind(r.dialect.BindType, query)
+}
+
+// GetLastEvents returns top *limit* events (events with the highest sequence numbers),
+// by sequence number in ascending order, ignore events with null sequence numbers
+func (r *Repository) GetLastEvents(ctx context.Context, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	query := fmt.Sprintf(`
+SELECT id, seq, data FROM %s
+WHERE seq IS NOT NULL
+ORDER BY seq DESC LIMIT ?
+`, r.eventTableName)
+
+	var result []cacheinv.InvalidateEvent
+	err := r.db.SelectContext(ctx, &result, r.rebind(query), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Seq.Int64 < result[j].Seq.Int64
+	})
+
+	if len(result) > 0 {
+		lastSeq := result[len(result)-1].GetSequence()
+		dbmetrics.EventLastUpdatedSeq.WithLabelValues(r.pipelineName).Set(float64(lastSeq))
+	}
+
+	return result, nil
+}
+
+// GetUnprocessedEvents returns list of events with the smallest event *id* (not sequence number)
+// *AND* have NULL sequence numbers, in ascending order of event *id*
+// size of the list is limited by *limit*
+func (r *Repository) GetUnprocessedEvents(ctx context.Context, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	query := fmt.Sprintf(`
+SELECT id, seq, data FROM %s
+WHERE seq IS NULL
+ORDER BY id LIMIT ?
+`, r.eventTableName)
+	var result []cacheinv.InvalidateEvent
+	err := r.db.SelectContext(ctx, &result, r.rebind(query), limit)
+	return result, err
+}
+
+// GetEventsFrom returns list of events with sequence number >= *from*
+// in ascending order of event sequence numbers, ignoring events with null sequence numbers
+// size of the list is limited by *limit*
+func (r *Repository) GetEventsFrom(ctx context.Context, from uint64, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	query := fmt.Sprintf(`
+SELECT id, seq, data FROM %s
+WHERE seq >= ?
+ORDER BY seq LIMIT ?
+`, r.eventTableName)
+	var result []cacheinv.InvalidateEvent
+	err := r.db.SelectContext(ctx, &result, r.rebind(query), from, limit)
+	return result, err
+}
+
+// UpdateSequences updates only sequence numbers of *events*,
+// the events must exist and have null sequence numbers, otherwise nothing will be updated
+func (r *Repository) UpdateSequences(ctx context.Context, events []cacheinv.InvalidateEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	affected, err := r.dialect.UpdateSequences(ctx, tx, r.eventTableName, events)
+	if err != nil {
+		return err
+	}
+	if affected != int64(len(events)) {
+		return r.dialect.ErrSequenceAlreadyUpdated
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	dbmetrics.EventLastUpdatedSeq.WithLabelValues(r.pipelineName).Set(float64(events[len(events)-1].GetSequence()))
+	return nil
+}
+
+// GetMinSequence returns the min sequence number of all events (except events with null sequence numbers)
+// returns null if no events with sequence number existed
+func (r *Repository) GetMinSequence(ctx context.Context) (sql.NullInt64, error) {
+	query := fmt.Sprintf(`SELECT MIN(seq) FROM %s`, r.eventTableName)
+	var result sql.NullInt64
+	err := r.db.GetContext(ctx, &result, query)
+	if result.Valid {
+		dbmetrics.EventMinRemainingSeq.WithLabelValues(r.pipelineName).Set(float64(result.Int64))
+	}
+	return result, err
+}
+
+// DeleteEventsBefore deletes events with sequence number < *beforeSeq*
+func (r *Repository) DeleteEventsBefore(ctx context.Context, beforeSeq uint64) error {
+	query := fmt.Sprintf(`SELECT id FROM %s WHERE seq = ?`, r.eventTableName)
+	var selectedID int64
+	err := r.db.GetContext(ctx, &selectedID, r.rebind(query), beforeSeq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	query = fmt.Sprintf(`DELETE FROM %s WHERE id < ?`, r.eventTableName)
+	_, err = r.db.ExecContext(ctx, r.rebind(query), selectedID)
+	if err == nil {
+		dbmetrics.EventMinRemainingSeq.WithLabelValues(r.pipelineName).Set(float64(beforeSeq))
+	}
+	return err
+}
+
+// GetLatestSequenceOlderThan returns the sequence number of the latest created event
+// among events created more than *maxAge* ago (except events with null sequence numbers),
+// returns null if no such events existed
+func (r *Repository) GetLatestSequenceOlderThan(ctx context.Context, maxAge time.Duration) (sql.NullInt64, error) {
+	cond, arg := r.dialect.CreatedBefore(maxAge)
+	query := fmt.Sprintf(`
+SELECT seq FROM %s
+WHERE %s AND seq IS NOT NULL
+ORDER BY created_at DESC, id DESC LIMIT 1
+`, r.eventTableName, cond)
+	var result sql.NullInt64
+	err := r.db.GetContext(ctx, &result, r.rebind(query), arg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.NullInt64{}, nil
+		}
+		return sql.NullInt64{}, err
+	}
+	return result, nil
+}
+
+// InvalidateOffset ...
+type InvalidateOffset struct {
+	ServerName string `db:"server_name"`
+	LastSeq    int64  `db:"last_seq"`
+}
+
+// GetLastSequence get from invalidate_offsets table
+func (r *Repository) GetLastSequence(ctx context.Context, serverName string) (sql.NullInt64, error) {
+	query := fmt.Sprintf(`
+SELECT server_name, last_seq FROM %s
+WHERE server_name = ?
+`, r.offsetTableName)
+	var result InvalidateOffset
+	err := r.db.GetContext(ctx, &result, r.rebind(query), serverName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.NullInt64{}, nil
+		}
+		return sql.NullInt64{}, err
+	}
+	return sql.NullInt64{
+		Valid: true,
+		Int64: result.LastSeq,
+	}, nil
+}
+
+// SetLastSequence upsert into invalidate_offsets table
+func (r *Repository) SetLastSequence(ctx context.Context, serverName string, seq int64) error {
+	query := fmt.Sprintf(`
+INSERT INTO %s (server_name, last_seq)
+VALUES (:server_name, :last_seq)
+ON CONFLICT (server_name) DO UPDATE SET last_seq = excluded.last_seq, updated_at = %s
+`, r.offsetTableName, r.dialect.Now)
+	_, err := r.db.NamedExecContext(ctx, query, InvalidateOffset{
+		ServerName: serverName,
+		LastSeq:    seq,
+	})
+	return err
+}
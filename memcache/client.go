@@ -2,8 +2,11 @@ package memcache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/QuangTung97/go-memcache/memcache"
 
@@ -11,25 +14,131 @@ import (
 )
 
 type clientImpl struct {
+	conf clientConfig
+
 	serverIDs []int64
-	clients   map[int64]*memcache.Client
+	pools     map[int64]*serverPool
 }
 
 var _ cacheinv.Client = &clientImpl{}
+var _ cacheinv.VersionedClient = &clientImpl{}
+var _ cacheinv.ExpireClient = &clientImpl{}
+var _ cacheinv.FlushClient = &clientImpl{}
+
+// Pool is a memcache server of multiple nodes, keys are sharded across the nodes by the hash scheme
+type Pool struct {
+	// Scheme is the hash scheme, default is HashSchemeKetama
+	Scheme HashScheme
+	Nodes  []PoolNode
+}
+
+// PoolNode ...
+type PoolNode struct {
+	// Addr is the address of the node used for hashing the keys, must be the same as used by the applications
+	Addr   string
+	Client *memcache.Client
+}
 
 // NewClient ...
-func NewClient(clients map[int64]*memcache.Client) cacheinv.Client {
-	servers := make([]int64, 0, len(clients))
-	for serverID := range clients {
+func NewClient(clients map[int64]*memcache.Client, options ...Option) cacheinv.Client {
+	pools := make(map[int64]Pool, len(clients))
+	for serverID, client := range clients {
+		pools[serverID] = Pool{
+			Nodes: []PoolNode{{Client: client}},
+		}
+	}
+	return NewPoolClient(pools, options...)
+}
+
+// NewPoolClient creates a client with each cache server being a pool of memcache nodes,
+// each key is deleted on the node owning the key by the hash scheme of the pool
+func NewPoolClient(pools map[int64]Pool, options ...Option) cacheinv.Client {
+	servers := make([]int64, 0, len(pools))
+	serverPools := make(map[int64]*serverPool, len(pools))
+
+	for serverID, pool := range pools {
 		servers = append(servers, serverID)
+		serverPools[serverID] = newServerPool(pool)
 	}
 	sort.Slice(servers, func(i, j int) bool {
 		return servers[i] < servers[j]
 	})
 
 	return &clientImpl{
+		conf: newClientConfig(options),
+
 		serverIDs: servers,
-		clients:   clients,
+		pools:     serverPools,
+	}
+}
+
+type serverPool struct {
+	clients  []*memcache.Client
+	selector nodeSelector
+}
+
+func newServerPool(pool Pool) *serverPool {
+	if len(pool.Nodes) == 0 {
+		panic("memcache: pool nodes must not be empty")
+	}
+
+	scheme := pool.Scheme
+	if len(scheme) == 0 {
+		scheme = HashSchemeKetama
+	}
+
+	p := &serverPool{}
+
+	addrs := make([]string, 0, len(pool.Nodes))
+	for _, node := range pool.Nodes {
+		p.clients = append(p.clients, node.Client)
+		addrs = append(addrs, node.Addr)
+	}
+
+	if len(pool.Nodes) > 1 {
+		p.selector = newNodeSelector(scheme, addrs)
+	}
+	return p
+}
+
+func (p *serverPool) nodeIndex(key string) int {
+	if p.selector == nil {
+		return 0
+	}
+	return p.selector.selectNode(key)
+}
+
+// poolPipelines lazily creates a pipeline for each node of the pool
+type poolPipelines struct {
+	pool  *serverPool
+	pipes []*memcache.Pipeline
+}
+
+func (c *clientImpl) newPipelines(serverID int64) *poolPipelines {
+	pool := c.pools[serverID]
+	return &poolPipelines{
+		pool:  pool,
+		pipes: make([]*memcache.Pipeline, len(pool.clients)),
+	}
+}
+
+// forKey returns the pipeline of the node owning the key
+func (p *poolPipelines) forKey(key string) *memcache.Pipeline {
+	index := p.pool.nodeIndex(key)
+
+	pipe := p.pipes[index]
+	if pipe == nil {
+		pipe = p.pool.clients[index].Pipeline()
+		p.pipes[index] = pipe
+	}
+	return pipe
+}
+
+func (p *poolPipelines) finish() {
+	for _, pipe := range p.pipes {
+		if pipe != nil {
+			pipe.Finish()
+		}
 	}
 }
 
@@ -45,14 +154,51 @@ func (c *clientImpl) GetServerName(serverID int64) string {
 
 // DeleteCacheKeys ...
 func (c *clientImpl) DeleteCacheKeys(_ context.Context, serverID int64, keys []string) error {
-	client := c.clients[serverID]
+	return c.pipelineDelete(serverID, keys, c.deleteOptions(serverID, 0))
+}
+
+// ExpireCacheKeys marks the keys as stale and sets their TTLs to *ttl* (rounded up to seconds)
+// using meta delete with flag I, clients using leases can still get the stale values while one of them refreshes
+func (c *clientImpl) ExpireCacheKeys(_ context.Context, serverID int64, keys []string, ttl time.Duration) error {
+	return c.pipelineDelete(serverID, keys, memcache.MDelOptions{
+		I:   true,
+		TTL: ttlSeconds(ttl),
+	})
+}
 
+// FlushCache invalidates all items of the memcache server using flush_all, on all nodes of the pool
+func (c *clientImpl) FlushCache(_ context.Context, serverID int64) error {
+	for _, client := range c.pools[serverID].clients {
+		err := flushNode(client)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func flushNode(client *memcache.Client) error {
 	pipe := client.Pipeline()
 	defer pipe.Finish()
 
+	return pipe.FlushAll()()
+}
+
+func ttlSeconds(ttl time.Duration) uint32 {
+	seconds := (ttl + time.Second - 1) / time.Second
+	if seconds < 1 {
+		return 1
+	}
+	return uint32(seconds)
+}
+
+func (c *clientImpl) pipelineDelete(serverID int64, keys []string, opts memcache.MDelOptions) error {
+	pipes := c.newPipelines(serverID)
+	defer pipes.finish()
+
 	fnList := make([]func() (memcache.MDelResponse, error), 0, len(keys))
 	for _, key := range keys {
-		fn := pipe.MDel(key, memcache.MDelOptions{})
+		fn := pipes.forKey(key).MDel(key, opts)
 		fnList = append(fnList, fn)
 	}
 
@@ -64,3 +210,104 @@ func (c *clientImpl) DeleteCacheKeys(_ context.Context, serverID int64, keys []s
 	}
 	return nil
 }
+
+func (c *clientImpl) deleteOptions(serverID int64, cas uint64) memcache.MDelOptions {
+	opts := memcache.MDelOptions{
+		CAS: cas,
+	}
+
+	_, invalidate := c.conf.invalidateServers[serverID]
+	if invalidate {
+		opts.I = true
+		opts.TTL = c.conf.invalidateTTL
+	}
+	return opts
+}
+
+// ErrVersionedDeleteConflict is returned when the cache items keep being changed while deleting
+var ErrVersionedDeleteConflict = errors.New("memcache: versioned delete conflicted with concurrent updates")
+
+const versionedDeleteMaxAttempts = 3
+
+// DeleteVersionedKeys deletes the keys only if the versions stored in the version keys
+// (see WithVersionKeySuffix) are lower than *version*.
+// The keys are deleted using the CAS values read together with the versions,
+// keys changed concurrently will be checked again
+func (c *clientImpl) DeleteVersionedKeys(_ context.Context, serverID int64, keys []string, version uint64) error {
+	pipes := c.newPipelines(serverID)
+	defer pipes.finish()
+
+	for attempt := 0; len(keys) > 0; attempt++ {
+		if attempt >= versionedDeleteMaxAttempts {
+			return ErrVersionedDeleteConflict
+		}
+
+		var err error
+		keys, err = c.tryDeleteVersionedKeys(pipes, serverID, keys, version)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tryDeleteVersionedKeys returns the keys that were changed between getting and deleting.
+// In a pool, the version keys can be on different nodes from the cache keys
+func (c *clientImpl) tryDeleteVersionedKeys(
+	pipes *poolPipelines, serverID int64, keys []string, version uint64,
+) ([]string, error) {
+	valueFnList := make([]func() (memcache.MGetResponse, error), 0, len(keys))
+	versionFnList := make([]func() (memcache.MGetResponse, error), 0, len(keys))
+	for _, key := range keys {
+		versionKey := key + c.conf.versionKeySuffix
+		valueFnList = append(valueFnList, pipes.forKey(key).MGet(key, memcache.MGetOptions{CAS: true}))
+		versionFnList = append(versionFnList, pipes.forKey(versionKey).MGet(versionKey, memcache.MGetOptions{}))
+	}
+
+	var deletedKeys []string
+	var delFnList []func() (memcache.MDelResponse, error)
+
+	for i, key := range keys {
+		valueResp, err := valueFnList[i]()
+		if err != nil {
+			return nil, err
+		}
+		versionResp, err := versionFnList[i]()
+		if err != nil {
+			return nil, err
+		}
+
+		if valueResp.Type != memcache.MGetResponseTypeVA {
+			continue
+		}
+		if isStoredVersionNotLower(versionResp, version) {
+			continue
+		}
+
+		deletedKeys = append(deletedKeys, key)
+		delFnList = append(delFnList, pipes.forKey(key).MDel(key, c.deleteOptions(serverID, valueResp.CAS)))
+	}
+
+	var conflictedKeys []string
+	for i, fn := range delFnList {
+		resp, err := fn()
+		if err != nil {
+			return nil, err
+		}
+		if resp.Type == memcache.MDelResponseTypeEX {
+			conflictedKeys = append(conflictedKeys, deletedKeys[i])
+		}
+	}
+	return conflictedKeys, nil
+}
+
+func isStoredVersionNotLower(resp memcache.MGetResponse, version uint64) bool {
+	if resp.Type != memcache.MGetResponseTypeVA {
+		return false
+	}
+	storedVersion, err := strconv.ParseUint(string(resp.Data), 10, 64)
+	if err != nil {
+		return false
+	}
+	return storedVersion >= version
+}
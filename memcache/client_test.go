@@ -2,8 +2,10 @@ package memcache
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/QuangTung97/go-memcache/memcache"
 	"github.com/stretchr/testify/assert"
@@ -39,7 +41,7 @@ func initClients() map[int64]*memcache.Client {
 	return globalClients
 }
 
-func newClientTest(_ *testing.T) *clientTest {
+func newClientTest(_ *testing.T, options ...Option) *clientTest {
 	clients := initClients()
 	for _, c := range clients {
 		pipe := c.Pipeline()
@@ -49,7 +51,7 @@ func newClientTest(_ *testing.T) *clientTest {
 
 	return &clientTest{
 		clients: clients,
-		client:  NewClient(clients),
+		client:  NewClient(clients, options...),
 	}
 }
 
@@ -103,6 +105,34 @@ func TestClient(t *testing.T) {
 		assert.Equal(t, "data03", string(resp3.Data))
 	})
 
+	t.Run("delete with invalidate", func(t *testing.T) {
+		c := newClientTest(t, WithInvalidateServers(11))
+
+		client1 := c.clients[11]
+
+		pipe := client1.Pipeline()
+		defer pipe.Finish()
+
+		// get lease
+		getResp, err := pipe.MGet("key01", memcache.MGetOptions{N: 30, CAS: true})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memcache.MGetFlagW, getResp.Flags)
+
+		// DO Invalidate
+		err = c.client.DeleteCacheKeys(context.Background(), 11, []string{"key01"})
+		assert.Equal(t, nil, err)
+
+		// Set with the old lease CAS
+		setResp, err := pipe.MSet("key01", []byte("stale"), memcache.MSetOptions{CAS: getResp.CAS})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memcache.MSetResponseTypeEX, setResp.Type)
+
+		// Get stale item
+		getResp, err = pipe.MGet("key01", memcache.MGetOptions{N: 30, CAS: true})()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, memcache.MGetFlagX, getResp.Flags&memcache.MGetFlagX)
+	})
+
 	t.Run("delete error", func(t *testing.T) {
 		c := newClientTest(t)
 
@@ -110,3 +140,196 @@ func TestClient(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestClient_DeleteVersionedKeys(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		c := newClientTest(t)
+
+		client1 := c.clients[11]
+
+		pipe := client1.Pipeline()
+		defer pipe.Finish()
+
+		setFnList := []func() (memcache.MSetResponse, error){
+			pipe.MSet("key01", []byte("data01"), memcache.MSetOptions{}),
+			pipe.MSet("key01:version", []byte("10"), memcache.MSetOptions{}),
+			pipe.MSet("key02", []byte("data02"), memcache.MSetOptions{}),
+			pipe.MSet("key02:version", []byte("11"), memcache.MSetOptions{}),
+			pipe.MSet("key03", []byte("data03"), memcache.MSetOptions{}),
+			pipe.MSet("key03:version", []byte("12"), memcache.MSetOptions{}),
+			pipe.MSet("key04", []byte("data04"), memcache.MSetOptions{}),
+		}
+		for _, fn := range setFnList {
+			_, err := fn()
+			assert.Equal(t, nil, err)
+		}
+
+		versionedClient, ok := c.client.(cacheinv.VersionedClient)
+		assert.Equal(t, true, ok)
+
+		err := versionedClient.DeleteVersionedKeys(
+			context.Background(), 11,
+			[]string{"key01", "key02", "key03", "key04", "key05"}, 11,
+		)
+		assert.Equal(t, nil, err)
+
+		// Get Cache Keys
+		fn1 := pipe.MGet("key01", memcache.MGetOptions{})
+		fn2 := pipe.MGet("key02", memcache.MGetOptions{})
+		fn3 := pipe.MGet("key03", memcache.MGetOptions{})
+		fn4 := pipe.MGet("key04", memcache.MGetOptions{})
+
+		resp1, err := fn1()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "", string(resp1.Data))
+
+		resp2, err := fn2()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data02", string(resp2.Data))
+
+		resp3, err := fn3()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data03", string(resp3.Data))
+
+		resp4, err := fn4()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "", string(resp4.Data))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := newClientTest(t)
+
+		versionedClient, ok := c.client.(cacheinv.VersionedClient)
+		assert.Equal(t, true, ok)
+
+		err := versionedClient.DeleteVersionedKeys(context.Background(), 12, []string{"key01"}, 11)
+		assert.Error(t, err)
+	})
+}
+
+func TestIsStoredVersionNotLower(t *testing.T) {
+	resp := memcache.MGetResponse{Type: memcache.MGetResponseTypeEN}
+	assert.Equal(t, false, isStoredVersionNotLower(resp, 10))
+
+	resp = memcache.MGetResponse{Type: memcache.MGetResponseTypeVA, Data: []byte("9")}
+	assert.Equal(t, false, isStoredVersionNotLower(resp, 10))
+
+	resp = memcache.MGetResponse{Type: memcache.MGetResponseTypeVA, Data: []byte("10")}
+	assert.Equal(t, true, isStoredVersionNotLower(resp, 10))
+
+	resp = memcache.MGetResponse{Type: memcache.MGetResponseTypeVA, Data: []byte("invalid")}
+	assert.Equal(t, false, isStoredVersionNotLower(resp, 10))
+}
+
+func TestClient_ExpireKeys(t *testing.T) {
+	c := newClientTest(t)
+
+	client1 := c.clients[11]
+
+	pipe := client1.Pipeline()
+	defer pipe.Finish()
+
+	_, err := pipe.MSet("key01", []byte("data01"), memcache.MSetOptions{})()
+	assert.Equal(t, nil, err)
+
+	expireClient, ok := c.client.(cacheinv.ExpireClient)
+	assert.Equal(t, true, ok)
+
+	err = expireClient.ExpireCacheKeys(context.Background(), 11, []string{"key01"}, 10*time.Second)
+	assert.Equal(t, nil, err)
+
+	// Get stale item and lease
+	getResp, err := pipe.MGet("key01", memcache.MGetOptions{N: 30, CAS: true})()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "data01", string(getResp.Data))
+	assert.Equal(t, memcache.MGetFlagX, getResp.Flags&memcache.MGetFlagX)
+	assert.Equal(t, memcache.MGetFlagW, getResp.Flags&memcache.MGetFlagW)
+}
+
+func TestTTLSeconds(t *testing.T) {
+	assert.Equal(t, uint32(1), ttlSeconds(0))
+	assert.Equal(t, uint32(1), ttlSeconds(300*time.Millisecond))
+	assert.Equal(t, uint32(10), ttlSeconds(10*time.Second))
+	assert.Equal(t, uint32(11), ttlSeconds(10*time.Second+time.Millisecond))
+}
+
+func TestClient_FlushCache(t *testing.T) {
+	c := newClientTest(t)
+
+	pipe := c.clients[11].Pipeline()
+	defer pipe.Finish()
+
+	_, err := pipe.MSet("key01", []byte("data01"), memcache.MSetOptions{})()
+	assert.Equal(t, nil, err)
+
+	flushClient, ok := c.client.(cacheinv.FlushClient)
+	assert.Equal(t, true, ok)
+
+	err = flushClient.FlushCache(context.Background(), 11)
+	assert.Equal(t, nil, err)
+
+	getResp, err := pipe.MGet("key01", memcache.MGetOptions{})()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, memcache.MGetResponseTypeEN, getResp.Type)
+}
+
+func TestPoolClient(t *testing.T) {
+	newPoolTest := func(t *testing.T) (*clientTest, []string) {
+		c := newClientTest(t)
+
+		// the node02 uses the address of the memcache server 12, which is not started
+		c.client = NewPoolClient(map[int64]Pool{
+			31: {
+				Scheme: HashSchemeRendezvous,
+				Nodes: []PoolNode{
+					{Addr: "node01", Client: c.clients[11]},
+					{Addr: "node02", Client: c.clients[12]},
+				},
+			},
+		})
+
+		selector := newNodeSelector(HashSchemeRendezvous, []string{"node01", "node02"})
+		var node01Keys []string
+		for i := 0; len(node01Keys) < 2; i++ {
+			key := fmt.Sprintf("key%02d", i)
+			if selector.selectNode(key) == 0 {
+				node01Keys = append(node01Keys, key)
+			}
+		}
+		return c, node01Keys
+	}
+
+	t.Run("delete on owner nodes", func(t *testing.T) {
+		c, keys := newPoolTest(t)
+
+		pipe := c.clients[11].Pipeline()
+		defer pipe.Finish()
+
+		for _, key := range keys {
+			_, err := pipe.MSet(key, []byte("data"), memcache.MSetOptions{})()
+			assert.Equal(t, nil, err)
+		}
+
+		err := c.client.DeleteCacheKeys(context.Background(), 31, keys)
+		assert.Equal(t, nil, err)
+
+		for _, key := range keys {
+			resp, err := pipe.MGet(key, memcache.MGetOptions{})()
+			assert.Equal(t, nil, err)
+			assert.Equal(t, memcache.MGetResponseTypeEN, resp.Type)
+		}
+	})
+
+	t.Run("delete error on other nodes", func(t *testing.T) {
+		c, _ := newPoolTest(t)
+
+		selector := newNodeSelector(HashSchemeRendezvous, []string{"node01", "node02"})
+		key := "key01"
+		for selector.selectNode(key) != 1 {
+			key += "0"
+		}
+
+		err := c.client.DeleteCacheKeys(context.Background(), 31, []string{key})
+		assert.Error(t, err)
+	})
+}
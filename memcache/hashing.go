@@ -0,0 +1,115 @@
+package memcache
+
+import (
+	"crypto/md5"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// HashScheme is the scheme for sharding keys across the nodes of a memcache server pool
+type HashScheme string
+
+const (
+	// HashSchemeKetama is the consistent hashing compatible with libketama:
+	// 160 points per node on the ring, from MD5 of '<node addr>-<i>' for i in [0, 40)
+	HashSchemeKetama HashScheme = "ketama"
+	// HashSchemeRendezvous is the highest random weight hashing,
+	// the node with the highest FNV-1a 64-bit hash of '<node addr><key>' is selected
+	HashSchemeRendezvous HashScheme = "rendezvous"
+)
+
+type nodeSelector interface {
+	// selectNode returns the index of the node owning the key
+	selectNode(key string) int
+}
+
+func newNodeSelector(scheme HashScheme, nodeAddrs []string) nodeSelector {
+	switch scheme {
+	case HashSchemeKetama:
+		return newKetamaSelector(nodeAddrs)
+	case HashSchemeRendezvous:
+		return &rendezvousSelector{nodeAddrs: nodeAddrs}
+	default:
+		panic(fmt.Sprintf("memcache: invalid hash scheme '%s'", scheme))
+	}
+}
+
+const (
+	ketamaHashesPerNode = 40
+	ketamaPointsPerHash = 4
+)
+
+type ketamaPoint struct {
+	value     uint32
+	nodeIndex int
+}
+
+type ketamaSelector struct {
+	points []ketamaPoint
+}
+
+func newKetamaSelector(nodeAddrs []string) *ketamaSelector {
+	points := make([]ketamaPoint, 0, len(nodeAddrs)*ketamaHashesPerNode*ketamaPointsPerHash)
+	for nodeIndex, addr := range nodeAddrs {
+		for i := 0; i < ketamaHashesPerNode; i++ {
+			digest := md5.Sum([]byte(fmt.Sprintf("%s-%d", addr, i)))
+			for h := 0; h < ketamaPointsPerHash; h++ {
+				points = append(points, ketamaPoint{
+					value:     ketamaPointValue(digest, h),
+					nodeIndex: nodeIndex,
+				})
+			}
+		}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].value < points[j].value
+	})
+
+	return &ketamaSelector{points: points}
+}
+
+func ketamaPointValue(digest [md5.Size]byte, h int) uint32 {
+	return uint32(digest[3+h*4])<<24 | uint32(digest[2+h*4])<<16 |
+		uint32(digest[1+h*4])<<8 | uint32(digest[h*4])
+}
+
+func ketamaHash(key string) uint32 {
+	return ketamaPointValue(md5.Sum([]byte(key)), 0)
+}
+
+// selectNode returns the node of the first point >= the hash of the key, wrapping around the ring
+func (s *ketamaSelector) selectNode(key string) int {
+	hash := ketamaHash(key)
+
+	index := sort.Search(len(s.points), func(i int) bool {
+		return s.points[i].value >= hash
+	})
+	if index == len(s.points) {
+		index = 0
+	}
+	return s.points[index].nodeIndex
+}
+
+type rendezvousSelector struct {
+	nodeAddrs []string
+}
+
+func (s *rendezvousSelector) selectNode(key string) int {
+	result := 0
+	var maxScore uint64
+
+	for i, addr := range s.nodeAddrs {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(addr))
+		_, _ = h.Write([]byte(key))
+
+		score := h.Sum64()
+		if i == 0 || score > maxScore {
+			result = i
+			maxScore = score
+		}
+	}
+	return result
+}
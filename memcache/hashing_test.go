@@ -0,0 +1,75 @@
+package memcache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKetamaHash(t *testing.T) {
+	assert.Equal(t, uint32(3191489131), ketamaHash("key01"))
+
+	s := newKetamaSelector([]string{"10.0.0.1:11211"})
+	assert.Equal(t, 160, len(s.points))
+
+	var values []uint32
+	for _, p := range s.points {
+		values = append(values, p.value)
+	}
+	for _, v := range []uint32{1644766326, 266575842, 1549369152, 2004188753} {
+		assert.Contains(t, values, v)
+	}
+}
+
+func selectAllNodes(s nodeSelector, numKeys int) []int {
+	result := make([]int, 0, numKeys)
+	for i := 0; i < numKeys; i++ {
+		result = append(result, s.selectNode(fmt.Sprintf("key:%d", i)))
+	}
+	return result
+}
+
+func TestNodeSelector(t *testing.T) {
+	const numKeys = 10000
+
+	addrs := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	newAddrs := append(addrs[:len(addrs):len(addrs)], "10.0.0.4:11211")
+
+	for _, scheme := range []HashScheme{HashSchemeKetama, HashSchemeRendezvous} {
+		t.Run(string(scheme), func(t *testing.T) {
+			nodes := selectAllNodes(newNodeSelector(scheme, addrs), numKeys)
+
+			// deterministic
+			assert.Equal(t, nodes, selectAllNodes(newNodeSelector(scheme, addrs), numKeys))
+
+			// distributed across all nodes
+			counts := make([]int, len(addrs))
+			for _, index := range nodes {
+				counts[index]++
+			}
+			for _, count := range counts {
+				assert.Greater(t, count, numKeys/len(addrs)/2)
+			}
+
+			// adding a node only moves keys to the new node
+			newNodes := selectAllNodes(newNodeSelector(scheme, newAddrs), numKeys)
+			moved := 0
+			for i := range nodes {
+				if nodes[i] == newNodes[i] {
+					continue
+				}
+				moved++
+				assert.Equal(t, 3, newNodes[i])
+			}
+			assert.Greater(t, moved, numKeys/len(newAddrs)/2)
+			assert.Less(t, moved, numKeys/len(newAddrs)*2)
+		})
+	}
+}
+
+func TestNewNodeSelector_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "memcache: invalid hash scheme 'another'", func() {
+		newNodeSelector("another", []string{"10.0.0.1:11211"})
+	})
+}
@@ -0,0 +1,50 @@
+package memcache
+
+type clientConfig struct {
+	invalidateServers map[int64]struct{}
+	invalidateTTL     uint32
+	versionKeySuffix  string
+}
+
+func newClientConfig(options []Option) clientConfig {
+	conf := clientConfig{
+		invalidateServers: map[int64]struct{}{},
+		invalidateTTL:     0,
+		versionKeySuffix:  ":version",
+	}
+
+	for _, fn := range options {
+		fn(&conf)
+	}
+
+	return conf
+}
+
+// Option ...
+type Option func(conf *clientConfig)
+
+// WithInvalidateServers marks cache items as stale with a new CAS value (meta delete with flag I)
+// instead of deleting them, on the servers with *serverIDs*.
+// Lease holders of the stale items will fail to set the items using the old CAS values
+func WithInvalidateServers(serverIDs ...int64) Option {
+	return func(conf *clientConfig) {
+		for _, id := range serverIDs {
+			conf.invalidateServers[id] = struct{}{}
+		}
+	}
+}
+
+// WithInvalidateTTL configures the TTL (in seconds) of the stale items, the TTL is unchanged if = 0
+func WithInvalidateTTL(ttl uint32) Option {
+	return func(conf *clientConfig) {
+		conf.invalidateTTL = ttl
+	}
+}
+
+// WithVersionKeySuffix configures the suffix of the keys storing the versions of the cached values.
+// For a cache key 'key01', its version is stored as a decimal number in the key 'key01' + suffix
+func WithVersionKeySuffix(suffix string) Option {
+	return func(conf *clientConfig) {
+		conf.versionKeySuffix = suffix
+	}
+}
@@ -0,0 +1,190 @@
+package memtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+// Call is a recorded call of Client
+type Call struct {
+	ServerID int64
+	Op       cacheinv.Operation
+
+	// Keys is the list of cache keys, or list of patterns / tags depending on Op
+	Keys []string
+
+	// Version is only used by cacheinv.OperationVersionedDelete
+	Version uint64
+	// TTL is only used by cacheinv.OperationExpire
+	TTL time.Duration
+	// EventSeq is only used by cacheinv.OperationPattern
+	EventSeq uint64
+}
+
+// Client is a thread-safe cacheinv.Client recording all calls,
+// it also implements all optional interfaces of cacheinv.Client
+type Client struct {
+	serverIDs []int64
+
+	mut       sync.Mutex
+	calls     []Call
+	errorFunc func(call Call) error
+}
+
+var _ cacheinv.Client = &Client{}
+var _ cacheinv.PatternClient = &Client{}
+var _ cacheinv.TagClient = &Client{}
+var _ cacheinv.VersionedClient = &Client{}
+var _ cacheinv.ExpireClient = &Client{}
+var _ cacheinv.FlushClient = &Client{}
+
+// OperationFlush is the Op of the calls recorded by FlushCache
+const OperationFlush cacheinv.Operation = "flush"
+
+// NewClient creates a Client with servers named 'memtest:<server id>'
+func NewClient(serverIDs ...int64) *Client {
+	return &Client{
+		serverIDs: serverIDs,
+	}
+}
+
+// SetErrorFunc sets the hook for failure injection, the calls returning non-nil errors by *fn*
+// are not recorded. Set *fn* = nil to disable
+func (c *Client) SetErrorFunc(fn func(call Call) error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.errorFunc = fn
+}
+
+// GetServerIDs ...
+func (c *Client) GetServerIDs() []int64 {
+	return c.serverIDs
+}
+
+// GetServerName ...
+func (*Client) GetServerName(serverID int64) string {
+	return fmt.Sprintf("memtest:%d", serverID)
+}
+
+// GetServerNames returns names of all servers, useful for Repository.WaitForSequence
+func (c *Client) GetServerNames() []string {
+	names := make([]string, 0, len(c.serverIDs))
+	for _, id := range c.serverIDs {
+		names = append(names, c.GetServerName(id))
+	}
+	return names
+}
+
+// GetCalls returns all recorded calls
+func (c *Client) GetCalls() []Call {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// GetServerCalls returns the recorded calls of a server
+func (c *Client) GetServerCalls(serverID int64) []Call {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	var result []Call
+	for _, call := range c.calls {
+		if call.ServerID == serverID {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+// GetDeletedKeys returns the keys of cacheinv.OperationDelete calls of a server
+func (c *Client) GetDeletedKeys(serverID int64) []string {
+	var keys []string
+	for _, call := range c.GetServerCalls(serverID) {
+		if call.Op == cacheinv.OperationDelete {
+			keys = append(keys, call.Keys...)
+		}
+	}
+	return keys
+}
+
+// Reset clears the recorded calls
+func (c *Client) Reset() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.calls = nil
+}
+
+func (c *Client) record(call Call) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.errorFunc != nil {
+		err := c.errorFunc(call)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.calls = append(c.calls, call)
+	return nil
+}
+
+// DeleteCacheKeys ...
+func (c *Client) DeleteCacheKeys(_ context.Context, serverID int64, keys []string) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       cacheinv.OperationDelete,
+		Keys:     keys,
+	})
+}
+
+// DeleteCachePattern ...
+func (c *Client) DeleteCachePattern(_ context.Context, serverID int64, eventSeq uint64, pattern string) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       cacheinv.OperationPattern,
+		Keys:     []string{pattern},
+		EventSeq: eventSeq,
+	})
+}
+
+// DeleteCacheTags ...
+func (c *Client) DeleteCacheTags(_ context.Context, serverID int64, tags []string) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       cacheinv.OperationTag,
+		Keys:     tags,
+	})
+}
+
+// DeleteVersionedKeys ...
+func (c *Client) DeleteVersionedKeys(_ context.Context, serverID int64, keys []string, version uint64) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       cacheinv.OperationVersionedDelete,
+		Keys:     keys,
+		Version:  version,
+	})
+}
+
+// ExpireCacheKeys ...
+func (c *Client) ExpireCacheKeys(_ context.Context, serverID int64, keys []string, ttl time.Duration) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       cacheinv.OperationExpire,
+		Keys:     keys,
+		TTL:      ttl,
+	})
+}
+
+// FlushCache ...
+func (c *Client) FlushCache(_ context.Context, serverID int64) error {
+	return c.record(Call{
+		ServerID: serverID,
+		Op:       OperationFlush,
+	})
+}
@@ -0,0 +1,72 @@
+package memtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		c := NewClient(11, 12)
+
+		assert.Equal(t, []int64{11, 12}, c.GetServerIDs())
+		assert.Equal(t, "memtest:11", c.GetServerName(11))
+		assert.Equal(t, []string{"memtest:11", "memtest:12"}, c.GetServerNames())
+
+		assert.Equal(t, nil, c.DeleteCacheKeys(ctx, 11, []string{"key01", "key02"}))
+		assert.Equal(t, nil, c.DeleteCachePattern(ctx, 12, 7, "user:*"))
+		assert.Equal(t, nil, c.DeleteCacheTags(ctx, 11, []string{"tag01"}))
+		assert.Equal(t, nil, c.DeleteVersionedKeys(ctx, 11, []string{"key03"}, 5))
+		assert.Equal(t, nil, c.ExpireCacheKeys(ctx, 11, []string{"key04"}, 10*time.Second))
+		assert.Equal(t, nil, c.DeleteCacheKeys(ctx, 11, []string{"key05"}))
+
+		assert.Equal(t, []Call{
+			{ServerID: 11, Op: cacheinv.OperationDelete, Keys: []string{"key01", "key02"}},
+			{ServerID: 11, Op: cacheinv.OperationTag, Keys: []string{"tag01"}},
+			{ServerID: 11, Op: cacheinv.OperationVersionedDelete, Keys: []string{"key03"}, Version: 5},
+			{ServerID: 11, Op: cacheinv.OperationExpire, Keys: []string{"key04"}, TTL: 10 * time.Second},
+			{ServerID: 11, Op: cacheinv.OperationDelete, Keys: []string{"key05"}},
+		}, c.GetServerCalls(11))
+
+		assert.Equal(t, []Call{
+			{ServerID: 12, Op: cacheinv.OperationPattern, Keys: []string{"user:*"}, EventSeq: 7},
+		}, c.GetServerCalls(12))
+
+		assert.Equal(t, []string{"key01", "key02", "key05"}, c.GetDeletedKeys(11))
+		assert.Equal(t, 6, len(c.GetCalls()))
+
+		c.Reset()
+		assert.Equal(t, 0, len(c.GetCalls()))
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		c := NewClient(11, 12)
+
+		injectedErr := errors.New("injected error")
+		c.SetErrorFunc(func(call Call) error {
+			if call.ServerID == 12 {
+				return injectedErr
+			}
+			return nil
+		})
+
+		assert.Equal(t, nil, c.DeleteCacheKeys(ctx, 11, []string{"key01"}))
+		assert.Equal(t, injectedErr, c.DeleteCacheKeys(ctx, 12, []string{"key02"}))
+
+		assert.Equal(t, []Call{
+			{ServerID: 11, Op: cacheinv.OperationDelete, Keys: []string{"key01"}},
+		}, c.GetCalls())
+
+		c.SetErrorFunc(nil)
+		assert.Equal(t, nil, c.DeleteCacheKeys(ctx, 12, []string{"key02"}))
+		assert.Equal(t, []string{"key02"}, c.GetDeletedKeys(12))
+	})
+}
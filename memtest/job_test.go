@@ -0,0 +1,418 @@
+package memtest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/QuangTung97/eventx"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/memtest"
+)
+
+type jobTest struct {
+	repo   *memtest.Repository
+	client *memtest.Client
+
+	wg  sync.WaitGroup
+	inv *cacheinv.InvalidatorJob
+}
+
+func newJobTest(t *testing.T, options ...cacheinv.Option) *jobTest {
+	repo := memtest.NewRepository()
+	client := memtest.NewClient(11, 12)
+
+	for _, name := range client.GetServerNames() {
+		err := repo.SetLastSequence(context.Background(), name, 0)
+		assert.Equal(t, nil, err)
+	}
+
+	j := &jobTest{
+		repo:   repo,
+		client: client,
+		inv:    cacheinv.NewInvalidatorJob(repo, client, options...),
+	}
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.inv.Run()
+	}()
+	t.Cleanup(func() {
+		j.inv.Shutdown()
+		j.wg.Wait()
+	})
+
+	return j
+}
+
+func (j *jobTest) waitForAllEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := j.repo.WaitForAllEvents(ctx, j.client.GetServerNames())
+	assert.Equal(t, nil, err)
+}
+
+func TestInvalidatorJob_Memtest(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.repo.InsertEvents("key01,key02", `{"v":1,"keys":["key03"],"servers":["memtest:12"]}`)
+		j.inv.Notify()
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, []string{"key01", "key02"}, j.client.GetDeletedKeys(11))
+		assert.Equal(t, []string{"key01", "key02", "key03"}, j.client.GetDeletedKeys(12))
+	})
+
+	t.Run("mixed operations in event order", func(t *testing.T) {
+		j := newJobTest(t, cacheinv.WithDoubleDelete(time.Hour, 100))
+
+		j.repo.InsertEvents(
+			`{"v":1,"op":"expire","keys":["key01"],"ttl":5}`,
+			"key01",
+			`{"v":1,"op":"pattern","keys":["user:*"]}`,
+			`{"v":1,"op":"expire","keys":["key02"],"ttl":5}`,
+		)
+		j.inv.Notify()
+
+		j.waitForAllEvents(t)
+
+		var ops []cacheinv.Operation
+		var keys []string
+		for _, call := range j.client.GetServerCalls(11) {
+			ops = append(ops, call.Op)
+			keys = append(keys, call.Keys...)
+		}
+		assert.Equal(t, []cacheinv.Operation{
+			cacheinv.OperationExpire,
+			cacheinv.OperationDelete,
+			cacheinv.OperationPattern,
+			cacheinv.OperationExpire,
+		}, ops)
+		assert.Equal(t, []string{"key01", "key01", "user:*", "key02"}, keys)
+	})
+
+	t.Run("skip invalid json payload", func(t *testing.T) {
+		j := newJobTest(t)
+
+		j.repo.InsertEvents(`{"v":1,"keys":["key01"`, "key02")
+		j.inv.Notify()
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, []string{"key02"}, j.client.GetDeletedKeys(11))
+		assert.Equal(t, []string{"key02"}, j.client.GetDeletedKeys(12))
+	})
+
+	t.Run("retry after client error", func(t *testing.T) {
+		var mut sync.Mutex
+		failedCount := 0
+		var loggedErrors []error
+
+		j := newJobTest(t,
+			cacheinv.WithRetryConsumerOptions(eventx.WithConsumerRetryDuration(20*time.Millisecond)),
+			cacheinv.WithRetryConsumerErrorLogger(func(err error) {
+				mut.Lock()
+				loggedErrors = append(loggedErrors, err)
+				mut.Unlock()
+			}),
+		)
+
+		j.client.SetErrorFunc(func(call memtest.Call) error {
+			mut.Lock()
+			defer mut.Unlock()
+
+			if call.ServerID == 12 && failedCount < 3 {
+				failedCount++
+				return errors.New("server error")
+			}
+			return nil
+		})
+
+		j.repo.InsertEvents("key01")
+		j.inv.Notify()
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, []string{"key01"}, j.client.GetDeletedKeys(11))
+		assert.Equal(t, []string{"key01"}, j.client.GetDeletedKeys(12))
+		mut.Lock()
+		assert.Equal(t, 3, failedCount)
+		assert.Equal(t, 3, len(loggedErrors))
+		mut.Unlock()
+	})
+
+	t.Run("age retention", func(t *testing.T) {
+		j := newJobTest(t,
+			cacheinv.WithRetentionMode(cacheinv.RetentionModeAge),
+			cacheinv.WithRetentionMaxAge(time.Hour, 10*time.Millisecond, 2),
+		)
+
+		j.repo.InsertEventsWithAge(2*time.Hour, "key01", "key02", "key03", "key04", "key05")
+		j.repo.InsertEvents("key06", "key07")
+		j.inv.Notify()
+
+		j.waitForAllEvents(t)
+		time.Sleep(100 * time.Millisecond)
+
+		// the latest expired event is kept
+		events := j.repo.GetAllEvents()
+		assert.Equal(t, 3, len(events))
+		assert.Equal(t, "key05", events[0].Data)
+		assert.Equal(t, int64(5), events[0].Seq.Int64)
+	})
+	t.Run("consumer aware retention", func(t *testing.T) {
+		j := newJobTest(t,
+			cacheinv.WithRetryConsumerOptions(eventx.WithConsumerRetryDuration(20*time.Millisecond)),
+			cacheinv.WithRetentionOptions(
+				eventx.WithMaxTotalEvents(5),
+				eventx.WithDeleteBatchSize(2),
+				eventx.WithRetentionErrorRetryDuration(10*time.Millisecond),
+			),
+			cacheinv.WithConsumerAwareRetention(0),
+		)
+
+		var mut sync.Mutex
+		serverFailed := true
+		j.client.SetErrorFunc(func(call memtest.Call) error {
+			mut.Lock()
+			defer mut.Unlock()
+
+			if call.ServerID == 12 && serverFailed {
+				return errors.New("server error")
+			}
+			return nil
+		})
+
+		for i := 0; i < 20; i++ {
+			j.repo.InsertEvents("key01")
+		}
+		j.inv.Notify()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := j.repo.WaitForSequence(ctx, []string{"memtest:11"}, 20)
+		assert.Equal(t, nil, err)
+		time.Sleep(100 * time.Millisecond)
+
+		// not consumed by server 12
+		assert.Equal(t, 20, len(j.repo.GetAllEvents()))
+
+		mut.Lock()
+		serverFailed = false
+		mut.Unlock()
+
+		j.waitForAllEvents(t)
+		time.Sleep(100 * time.Millisecond)
+
+		events := j.repo.GetAllEvents()
+		assert.Equal(t, 6, len(events))
+		assert.Equal(t, int64(15), events[0].Seq.Int64)
+	})
+
+	t.Run("consumer aware retention with hard cap", func(t *testing.T) {
+		j := newJobTest(t,
+			cacheinv.WithRetentionOptions(
+				eventx.WithMaxTotalEvents(5),
+				eventx.WithDeleteBatchSize(2),
+				eventx.WithRetentionErrorRetryDuration(10*time.Millisecond),
+			),
+			cacheinv.WithConsumerAwareRetention(10),
+		)
+
+		j.client.SetErrorFunc(func(call memtest.Call) error {
+			if call.ServerID == 12 {
+				return errors.New("server error")
+			}
+			return nil
+		})
+
+		for i := 0; i < 20; i++ {
+			j.repo.InsertEvents("key01")
+		}
+		j.inv.Notify()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := j.repo.WaitForSequence(ctx, []string{"memtest:11"}, 20)
+		assert.Equal(t, nil, err)
+		time.Sleep(100 * time.Millisecond)
+
+		// at most 10 events are kept
+		events := j.repo.GetAllEvents()
+		assert.Equal(t, 10, len(events))
+		assert.Equal(t, int64(11), events[0].Seq.Int64)
+	})
+}
+
+// newGapJobTest makes server 12 miss the events with seq from 3 to 10, which were deleted while it was failing
+func newGapJobTest(t *testing.T, options ...cacheinv.Option) *jobTest {
+	options = append(options,
+		cacheinv.WithRunnerOptions(eventx.WithCoreStoredEventsSize(4)),
+		cacheinv.WithRetryConsumerOptions(
+			eventx.WithConsumerRetryDuration(20*time.Millisecond),
+			eventx.WithRetryConsumerFetchLimit(2),
+		),
+	)
+	j := newJobTest(t, options...)
+
+	var mut sync.Mutex
+	serverFailed := true
+	j.client.SetErrorFunc(func(call memtest.Call) error {
+		mut.Lock()
+		defer mut.Unlock()
+
+		if call.ServerID == 12 && call.Op == cacheinv.OperationDelete && serverFailed {
+			return errors.New("server error")
+		}
+		return nil
+	})
+
+	for i := 1; i <= 20; i++ {
+		j.repo.InsertEvents(fmt.Sprintf("key%02d", i))
+	}
+	j.inv.Notify()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := j.repo.WaitForSequence(ctx, []string{"memtest:11"}, 20)
+	assert.Equal(t, nil, err)
+
+	err = j.repo.DeleteEventsBefore(ctx, 11)
+	assert.Equal(t, nil, err)
+
+	mut.Lock()
+	serverFailed = false
+	mut.Unlock()
+
+	return j
+}
+
+func TestInvalidatorJob_GapRecovery(t *testing.T) {
+	expectedKeys := []string{
+		"key01", "key02",
+		"key11", "key12", "key13", "key14", "key15",
+		"key16", "key17", "key18", "key19", "key20",
+	}
+
+	t.Run("flush", func(t *testing.T) {
+		j := newGapJobTest(t, cacheinv.WithGapRecovery(cacheinv.GapRecoveryFlush, ""))
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, expectedKeys, j.client.GetDeletedKeys(12))
+
+		calls := j.client.GetServerCalls(12)
+		assert.Equal(t, 7, len(calls))
+		assert.Equal(t, memtest.OperationFlush, calls[1].Op)
+
+		for _, call := range j.client.GetServerCalls(11) {
+			assert.Equal(t, cacheinv.OperationDelete, call.Op)
+		}
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		var mut sync.Mutex
+		var gaps []cacheinv.ConsumerGap
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var gap cacheinv.ConsumerGap
+			err := json.NewDecoder(r.Body).Decode(&gap)
+			assert.Equal(t, nil, err)
+
+			mut.Lock()
+			gaps = append(gaps, gap)
+			mut.Unlock()
+		}))
+		defer server.Close()
+
+		j := newGapJobTest(t, cacheinv.WithGapRecovery(cacheinv.GapRecoveryWebhook, server.URL))
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, expectedKeys, j.client.GetDeletedKeys(12))
+
+		mut.Lock()
+		assert.Equal(t, []cacheinv.ConsumerGap{
+			{ServerName: "memtest:12", LastSeq: 2, MinSeq: 11},
+		}, gaps)
+		mut.Unlock()
+	})
+
+	t.Run("retry after webhook error", func(t *testing.T) {
+		var mut sync.Mutex
+		requestCount := 0
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mut.Lock()
+			defer mut.Unlock()
+
+			requestCount++
+			if requestCount <= 2 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		defer server.Close()
+
+		j := newGapJobTest(t, cacheinv.WithGapRecovery(cacheinv.GapRecoveryWebhook, server.URL))
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, expectedKeys, j.client.GetDeletedKeys(12))
+
+		mut.Lock()
+		assert.Equal(t, 3, requestCount)
+		mut.Unlock()
+	})
+
+	t.Run("with error logger", func(t *testing.T) {
+		var mut sync.Mutex
+		var loggedErrors []error
+
+		j := newGapJobTest(t,
+			cacheinv.WithGapRecovery(cacheinv.GapRecoveryAlert, ""),
+			cacheinv.WithRetryConsumerErrorLogger(func(err error) {
+				mut.Lock()
+				loggedErrors = append(loggedErrors, err)
+				mut.Unlock()
+			}),
+		)
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, expectedKeys, j.client.GetDeletedKeys(12))
+
+		mut.Lock()
+		notFound := false
+		for _, err := range loggedErrors {
+			if errors.Is(err, eventx.ErrEventNotFound) {
+				notFound = true
+			}
+		}
+		assert.Equal(t, true, notFound)
+		mut.Unlock()
+	})
+
+	t.Run("alert", func(t *testing.T) {
+		j := newGapJobTest(t, cacheinv.WithGapRecovery(cacheinv.GapRecoveryAlert, ""))
+
+		j.waitForAllEvents(t)
+
+		assert.Equal(t, expectedKeys, j.client.GetDeletedKeys(12))
+		assert.Equal(t, 6, len(j.client.GetServerCalls(12)))
+	})
+}
@@ -0,0 +1,414 @@
+// Package memtest provides in-memory implementations of cacheinv.Repository and cacheinv.Client
+// for testing services embedding cacheinv.InvalidatorJob
+package memtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+// Method is the name of a method of Repository, used for failure injection
+type Method string
+
+const (
+	// MethodGetLastEvents ...
+	MethodGetLastEvents Method = "GetLastEvents"
+	// MethodGetUnprocessedEvents ...
+	MethodGetUnprocessedEvents Method = "GetUnprocessedEvents"
+	// MethodGetEventsFrom ...
+	MethodGetEventsFrom Method = "GetEventsFrom"
+	// MethodUpdateSequences ...
+	MethodUpdateSequences Method = "UpdateSequences"
+	// MethodGetMinSequence ...
+	MethodGetMinSequence Method = "GetMinSequence"
+	// MethodDeleteEventsBefore ...
+	MethodDeleteEventsBefore Method = "DeleteEventsBefore"
+	// MethodGetLastSequence ...
+	MethodGetLastSequence Method = "GetLastSequence"
+	// MethodSetLastSequence ...
+	MethodSetLastSequence Method = "SetLastSequence"
+	// MethodGetLatestSequenceOlderThan ...
+	MethodGetLatestSequenceOlderThan Method = "GetLatestSequenceOlderThan"
+)
+
+// ErrSequenceAlreadyUpdated is returned when some of the events not existed or already have sequence numbers
+var ErrSequenceAlreadyUpdated = errors.New("memtest: sequence numbers of events already updated")
+
+// Repository is a thread-safe in-memory cacheinv.Repository
+type Repository struct {
+	mut sync.Mutex
+
+	lastID    int64
+	events    []cacheinv.InvalidateEvent // in ascending order of id
+	createdAt map[int64]time.Time        // created time of events by id
+	offsets   map[string]int64
+
+	errorFunc func(method Method) error
+
+	// closed and replaced whenever events or offsets changed
+	changed chan struct{}
+}
+
+var _ cacheinv.Repository = &Repository{}
+var _ cacheinv.AgeRetentionRepository = &Repository{}
+
+// NewRepository creates an empty Repository.
+// Same as the SQL implementations, the consumers of servers without offsets start from the last event,
+// call SetLastSequence(serverName, 0) before running the job to make them process all events
+func NewRepository() *Repository {
+	return &Repository{
+		createdAt: map[int64]time.Time{},
+		offsets:   map[string]int64{},
+		changed:   make(chan struct{}),
+	}
+}
+
+// SetErrorFunc sets the hook for failure injection, the methods return the non-nil errors returned by *fn*
+// without doing anything. Set *fn* = nil to disable
+func (r *Repository) SetErrorFunc(fn func(method Method) error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.errorFunc = fn
+}
+
+// InsertEvents inserts events with null sequence numbers, returns the ids of the inserted events
+func (r *Repository) InsertEvents(dataList ...string) []int64 {
+	return r.InsertEventsWithAge(0, dataList...)
+}
+
+// InsertEventsWithAge is the same as InsertEvents, but the events are created *age* ago
+func (r *Repository) InsertEventsWithAge(age time.Duration, dataList ...string) []int64 {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	createdAt := time.Now().Add(-age)
+
+	ids := make([]int64, 0, len(dataList))
+	for _, data := range dataList {
+		r.lastID++
+		r.events = append(r.events, cacheinv.InvalidateEvent{
+			ID:   r.lastID,
+			Data: data,
+		})
+		r.createdAt[r.lastID] = createdAt
+		ids = append(ids, r.lastID)
+	}
+	r.notifyChanged()
+	return ids
+}
+
+// Reset removes all events and offsets, the event ids start from 1 again
+func (r *Repository) Reset() {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	r.lastID = 0
+	r.events = nil
+	r.createdAt = map[int64]time.Time{}
+	r.offsets = map[string]int64{}
+	r.notifyChanged()
+}
+
+// GetAllEvents returns all events in ascending order of id
+func (r *Repository) GetAllEvents() []cacheinv.InvalidateEvent {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return append([]cacheinv.InvalidateEvent(nil), r.events...)
+}
+
+func (r *Repository) notifyChanged() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *Repository) injectedError(method Method) error {
+	if r.errorFunc == nil {
+		return nil
+	}
+	return r.errorFunc(method)
+}
+
+func (r *Repository) eventsWithSequence() []cacheinv.InvalidateEvent {
+	var result []cacheinv.InvalidateEvent
+	for _, e := range r.events {
+		if e.Seq.Valid {
+			result = append(result, e)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Seq.Int64 < result[j].Seq.Int64
+	})
+	return result
+}
+
+// GetLastEvents returns top *limit* events (events with the highest sequence numbers),
+// by sequence number in ascending order, ignore events with null sequence numbers
+func (r *Repository) GetLastEvents(_ context.Context, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetLastEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	events := r.eventsWithSequence()
+	if uint64(len(events)) > limit {
+		events = events[uint64(len(events))-limit:]
+	}
+	return events, nil
+}
+
+// GetUnprocessedEvents returns list of events with the smallest event *id* (not sequence number)
+// *AND* have NULL sequence numbers, in ascending order of event *id*
+// size of the list is limited by *limit*
+func (r *Repository) GetUnprocessedEvents(_ context.Context, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetUnprocessedEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []cacheinv.InvalidateEvent
+	for _, e := range r.events {
+		if uint64(len(result)) >= limit {
+			break
+		}
+		if !e.Seq.Valid {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// GetEventsFrom returns list of events with sequence number >= *from*
+// in ascending order of event sequence numbers, ignoring events with null sequence numbers
+// size of the list is limited by *limit*
+func (r *Repository) GetEventsFrom(_ context.Context, from uint64, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetEventsFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []cacheinv.InvalidateEvent
+	for _, e := range r.eventsWithSequence() {
+		if uint64(len(result)) >= limit {
+			break
+		}
+		if e.GetSequence() >= from {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// UpdateSequences updates only sequence numbers of *events*,
+// the events must exist and have null sequence numbers, otherwise nothing will be updated
+func (r *Repository) UpdateSequences(_ context.Context, events []cacheinv.InvalidateEvent) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodUpdateSequences)
+	if err != nil {
+		return err
+	}
+
+	indices := make([]int, 0, len(events))
+	for _, e := range events {
+		index, ok := r.findEvent(e.ID)
+		if !ok || r.events[index].Seq.Valid {
+			return ErrSequenceAlreadyUpdated
+		}
+		indices = append(indices, index)
+	}
+
+	for i, index := range indices {
+		r.events[index].Seq = events[i].Seq
+	}
+	if len(events) > 0 {
+		r.notifyChanged()
+	}
+	return nil
+}
+
+func (r *Repository) findEvent(id int64) (int, bool) {
+	index := sort.Search(len(r.events), func(i int) bool {
+		return r.events[i].ID >= id
+	})
+	if index >= len(r.events) || r.events[index].ID != id {
+		return 0, false
+	}
+	return index, true
+}
+
+// GetMinSequence returns the min sequence number of all events (except events with null sequence numbers)
+// returns null if no events with sequence number existed
+func (r *Repository) GetMinSequence(_ context.Context) (sql.NullInt64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetMinSequence)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	events := r.eventsWithSequence()
+	if len(events) == 0 {
+		return sql.NullInt64{}, nil
+	}
+	return events[0].Seq, nil
+}
+
+// DeleteEventsBefore deletes events with sequence number < *beforeSeq*
+func (r *Repository) DeleteEventsBefore(_ context.Context, beforeSeq uint64) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodDeleteEventsBefore)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range r.events {
+		if e.Seq.Valid && e.GetSequence() == beforeSeq {
+			// same as the SQL implementations, delete by id
+			for _, deleted := range r.events[:i] {
+				delete(r.createdAt, deleted.ID)
+			}
+			r.events = append([]cacheinv.InvalidateEvent(nil), r.events[i:]...)
+			r.notifyChanged()
+			return nil
+		}
+	}
+	return nil
+}
+
+// GetLatestSequenceOlderThan returns the sequence number of the latest created event
+// among events created more than *maxAge* ago (except events with null sequence numbers),
+// returns null if no such events existed
+func (r *Repository) GetLatestSequenceOlderThan(_ context.Context, maxAge time.Duration) (sql.NullInt64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetLatestSequenceOlderThan)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	before := time.Now().Add(-maxAge)
+
+	var result sql.NullInt64
+	var latest time.Time
+	for _, e := range r.events {
+		createdAt := r.createdAt[e.ID]
+		if !e.Seq.Valid || !createdAt.Before(before) {
+			continue
+		}
+		// events are in ascending order of id, the later one wins when created at the same time
+		if !result.Valid || !createdAt.Before(latest) {
+			result = e.Seq
+			latest = createdAt
+		}
+	}
+	return result, nil
+}
+
+// GetLastSequence returns the offset of the server
+func (r *Repository) GetLastSequence(_ context.Context, serverName string) (sql.NullInt64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodGetLastSequence)
+	if err != nil {
+		return sql.NullInt64{}, err
+	}
+
+	seq, ok := r.offsets[serverName]
+	if !ok {
+		return sql.NullInt64{}, nil
+	}
+	return sql.NullInt64{
+		Valid: true,
+		Int64: seq,
+	}, nil
+}
+
+// SetLastSequence sets the offset of the server
+func (r *Repository) SetLastSequence(_ context.Context, serverName string, seq int64) error {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	err := r.injectedError(MethodSetLastSequence)
+	if err != nil {
+		return err
+	}
+
+	r.offsets[serverName] = seq
+	r.notifyChanged()
+	return nil
+}
+
+// WaitForSequence waits until the offsets of all *serverNames* >= *seq*
+func (r *Repository) WaitForSequence(ctx context.Context, serverNames []string, seq int64) error {
+	return r.waitUntil(ctx, func() bool {
+		return r.allOffsetsReached(serverNames, seq)
+	})
+}
+
+// WaitForAllEvents waits until all inserted events have sequence numbers
+// and have been applied to all *serverNames*
+func (r *Repository) WaitForAllEvents(ctx context.Context, serverNames []string) error {
+	return r.waitUntil(ctx, func() bool {
+		var maxSeq int64
+		for _, e := range r.events {
+			if !e.Seq.Valid {
+				return false
+			}
+			if e.Seq.Int64 > maxSeq {
+				maxSeq = e.Seq.Int64
+			}
+		}
+		return r.allOffsetsReached(serverNames, maxSeq)
+	})
+}
+
+func (r *Repository) allOffsetsReached(serverNames []string, seq int64) bool {
+	for _, name := range serverNames {
+		offset, ok := r.offsets[name]
+		if !ok || offset < seq {
+			return false
+		}
+	}
+	return true
+}
+
+// waitUntil calls *cond* with the lock held, whenever events or offsets changed
+func (r *Repository) waitUntil(ctx context.Context, cond func() bool) error {
+	for {
+		r.mut.Lock()
+		ok := cond()
+		changed := r.changed
+		r.mut.Unlock()
+
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package memtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/repotest"
+)
+
+func newInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{
+		Valid: true,
+		Int64: v,
+	}
+}
+
+func TestRepository_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Fixture {
+		r := NewRepository()
+		return repotest.Fixture{
+			Repo: r,
+			InsertEvents: func(dataList ...string) {
+				r.InsertEvents(dataList...)
+			},
+			InsertEventsWithAge: func(age time.Duration, dataList ...string) {
+				r.InsertEventsWithAge(age, dataList...)
+			},
+		}
+	})
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("update already updated events", func(t *testing.T) {
+		r := NewRepository()
+
+		ids := r.InsertEvents("key01", "key02")
+		assert.Equal(t, []int64{1, 2}, ids)
+
+		err := r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{
+			{ID: 1, Seq: newInt64(11)},
+		})
+		assert.Equal(t, nil, err)
+
+		err = r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{
+			{ID: 2, Seq: newInt64(12)},
+			{ID: 1, Seq: newInt64(21)},
+		})
+		assert.Equal(t, ErrSequenceAlreadyUpdated, err)
+
+		err = r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{
+			{ID: 3, Seq: newInt64(13)},
+		})
+		assert.Equal(t, ErrSequenceAlreadyUpdated, err)
+
+		assert.Equal(t, []cacheinv.InvalidateEvent{
+			{ID: 1, Seq: newInt64(11), Data: "key01"},
+			{ID: 2, Data: "key02"},
+		}, r.GetAllEvents())
+	})
+
+	t.Run("with error", func(t *testing.T) {
+		r := NewRepository()
+
+		injectedErr := errors.New("injected error")
+		var methods []Method
+		r.SetErrorFunc(func(method Method) error {
+			methods = append(methods, method)
+			return injectedErr
+		})
+
+		_, err := r.GetLastEvents(ctx, 16)
+		assert.Equal(t, injectedErr, err)
+
+		_, err = r.GetUnprocessedEvents(ctx, 16)
+		assert.Equal(t, injectedErr, err)
+
+		_, err = r.GetEventsFrom(ctx, 100, 16)
+		assert.Equal(t, injectedErr, err)
+
+		err = r.UpdateSequences(ctx, nil)
+		assert.Equal(t, injectedErr, err)
+
+		_, err = r.GetMinSequence(ctx)
+		assert.Equal(t, injectedErr, err)
+
+		err = r.DeleteEventsBefore(ctx, 100)
+		assert.Equal(t, injectedErr, err)
+
+		_, err = r.GetLastSequence(ctx, "server01")
+		assert.Equal(t, injectedErr, err)
+
+		err = r.SetLastSequence(ctx, "server01", 70)
+		assert.Equal(t, injectedErr, err)
+
+		assert.Equal(t, []Method{
+			MethodGetLastEvents,
+			MethodGetUnprocessedEvents,
+			MethodGetEventsFrom,
+			MethodUpdateSequences,
+			MethodGetMinSequence,
+			MethodDeleteEventsBefore,
+			MethodGetLastSequence,
+			MethodSetLastSequence,
+		}, methods)
+
+		r.SetErrorFunc(nil)
+
+		err = r.SetLastSequence(ctx, "server01", 70)
+		assert.Equal(t, nil, err)
+	})
+}
+
+func TestRepository_Wait(t *testing.T) {
+	ctx := context.Background()
+	servers := []string{"server01", "server02"}
+
+	t.Run("wait for sequence", func(t *testing.T) {
+		r := NewRepository()
+
+		done := make(chan error, 1)
+		go func() {
+			done <- r.WaitForSequence(ctx, servers, 10)
+		}()
+
+		_ = r.SetLastSequence(ctx, "server01", 10)
+		_ = r.SetLastSequence(ctx, "server02", 9)
+
+		select {
+		case <-done:
+			t.Fatal("must not complete")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		_ = r.SetLastSequence(ctx, "server02", 11)
+		assert.Equal(t, nil, <-done)
+	})
+
+	t.Run("wait for all events", func(t *testing.T) {
+		r := NewRepository()
+
+		r.InsertEvents("key01", "key02")
+
+		done := make(chan error, 1)
+		go func() {
+			done <- r.WaitForAllEvents(ctx, servers)
+		}()
+
+		_ = r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{{ID: 1, Seq: newInt64(1)}})
+		_ = r.SetLastSequence(ctx, "server01", 1)
+		_ = r.SetLastSequence(ctx, "server02", 1)
+
+		select {
+		case <-done:
+			t.Fatal("must not complete")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		_ = r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{{ID: 2, Seq: newInt64(2)}})
+		_ = r.SetLastSequence(ctx, "server01", 2)
+		_ = r.SetLastSequence(ctx, "server02", 2)
+
+		assert.Equal(t, nil, <-done)
+	})
+
+	t.Run("wait for all events after deleting events", func(t *testing.T) {
+		r := NewRepository()
+
+		r.InsertEvents("key01", "key02")
+
+		done := make(chan error, 1)
+		go func() {
+			done <- r.WaitForAllEvents(ctx, servers)
+		}()
+
+		_ = r.UpdateSequences(ctx, []cacheinv.InvalidateEvent{{ID: 2, Seq: newInt64(1)}})
+		_ = r.SetLastSequence(ctx, "server01", 1)
+		_ = r.SetLastSequence(ctx, "server02", 1)
+
+		select {
+		case <-done:
+			t.Fatal("must not complete")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		// the event without sequence number is deleted
+		err := r.DeleteEventsBefore(ctx, 1)
+		assert.Equal(t, nil, err)
+
+		select {
+		case err := <-done:
+			assert.Equal(t, nil, err)
+		case <-time.After(time.Second):
+			t.Fatal("must complete after deleting events")
+		}
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		r := NewRepository()
+
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+
+		err := r.WaitForSequence(ctx, servers, 10)
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+}
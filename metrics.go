@@ -0,0 +1,47 @@
+package cacheinv
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPipelineName is the default value of the label 'pipeline' of the metrics, see WithPipelineName
+const DefaultPipelineName = "default"
+
+// jobMetrics contains the metrics of a job, with the label 'pipeline' already set
+type jobMetrics struct {
+	errorTotal *prometheus.CounterVec
+
+	consumerLastSeq         *prometheus.GaugeVec
+	consumerAppliedEvents   *prometheus.CounterVec
+	consumerSkippedEvents   *prometheus.CounterVec
+	consumerGapRecoveries   *prometheus.CounterVec
+	doubleDeleteScheduled   *prometheus.CounterVec
+	doubleDeleteExecuted    *prometheus.CounterVec
+	doubleDeleteDropped     *prometheus.CounterVec
+	doubleDeletePendingKeys *prometheus.GaugeVec
+
+	ageRetentionDeletedEvents    prometheus.Counter
+	retentionBlockedByConsumers  prometheus.Gauge
+	retentionForcedDeletedEvents *prometheus.CounterVec
+}
+
+func newJobMetrics(pipelineName string) *jobMetrics {
+	labels := prometheus.Labels{"pipeline": pipelineName}
+
+	return &jobMetrics{
+		errorTotal: invalidatorJobErrorTotal.MustCurryWith(labels),
+
+		consumerLastSeq:         cacheConsumerLastSeq.MustCurryWith(labels),
+		consumerAppliedEvents:   cacheConsumerAppliedEventsTotal.MustCurryWith(labels),
+		consumerSkippedEvents:   cacheConsumerSkippedEventsTotal.MustCurryWith(labels),
+		consumerGapRecoveries:   cacheConsumerGapRecoveriesTotal.MustCurryWith(labels),
+		doubleDeleteScheduled:   doubleDeleteScheduledKeysTotal.MustCurryWith(labels),
+		doubleDeleteExecuted:    doubleDeleteExecutedKeysTotal.MustCurryWith(labels),
+		doubleDeleteDropped:     doubleDeleteDroppedKeysTotal.MustCurryWith(labels),
+		doubleDeletePendingKeys: doubleDeletePendingKeys.MustCurryWith(labels),
+
+		ageRetentionDeletedEvents:    ageRetentionDeletedEventsTotal.With(labels),
+		retentionBlockedByConsumers:  retentionBlockedByConsumers.With(labels),
+		retentionForcedDeletedEvents: retentionForcedDeletedEventsTotal.MustCurryWith(labels),
+	}
+}
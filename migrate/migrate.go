@@ -0,0 +1,253 @@
+// Package migrate applies the versioned schema migrations of the Repository implementations
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version     int
+	Description string
+
+	// SQL is a text/template of the statements separated by semicolons,
+	// with the fields of TableNames as parameters, e.g. {{.EventTableName}}
+	SQL string
+}
+
+// TableNames ...
+type TableNames struct {
+	EventTableName  string
+	OffsetTableName string
+}
+
+// SchemaVersionTableName returns the name of the table tracking the applied migrations
+func (n TableNames) SchemaVersionTableName() string {
+	return n.EventTableName + "_schema_version"
+}
+
+// Status is the status of a migration
+type Status struct {
+	Migration
+
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// ErrInvalidMigrationFile is returned when the file name is not in the format '<version>_<description>.sql'
+var ErrInvalidMigrationFile = errors.New("migrate: invalid migration file name")
+
+// Load reads the migrations from the files in *dir*,
+// file names must be in the format '<version>_<description>.sql', e.g. '0001_create_tables.sql'
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+
+		versionStr, desc, found := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(versionStr)
+		if !found || err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: '%s'", ErrInvalidMigrationFile, name)
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(desc, "_", " "),
+			SQL:         string(data),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// MustLoad is similar to Load, but panics on errors
+func MustLoad(fsys fs.FS, dir string) []Migration {
+	result, err := Load(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Migrator applies migrations and tracks the applied versions in the schema version table
+type Migrator struct {
+	db         *sqlx.DB
+	migrations []Migration
+	tableNames TableNames
+}
+
+// New ...
+func New(db *sqlx.DB, migrations []Migration, tableNames TableNames) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		tableNames: tableNames,
+	}
+}
+
+func (m *Migrator) createSchemaVersionTable(ctx context.Context) error {
+	query := fmt.Sprintf(`
+CREATE TABLE IF NOT EXISTS %s
+(
+    version     INT          NOT NULL PRIMARY KEY,
+    description VARCHAR(255) NOT NULL,
+    applied_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`, m.tableNames.SchemaVersionTableName())
+	_, err := m.db.ExecContext(ctx, query)
+	return err
+}
+
+type appliedVersion struct {
+	Version   int       `db:"version"`
+	AppliedAt time.Time `db:"applied_at"`
+}
+
+func (m *Migrator) getAppliedVersions(ctx context.Context) (map[int]time.Time, error) {
+	query := fmt.Sprintf(`SELECT version, applied_at FROM %s`, m.tableNames.SchemaVersionTableName())
+
+	var rows []appliedVersion
+	err := m.db.SelectContext(ctx, &rows, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[int]time.Time{}
+	for _, row := range rows {
+		result[row.Version] = row.AppliedAt
+	}
+	return result, nil
+}
+
+// Status returns the statuses of all migrations, in ascending order of versions
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	err := m.createSchemaVersionTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.getAppliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		result = append(result, Status{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return result, nil
+}
+
+// Up applies all pending migrations, returns the applied migrations
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Migration
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+
+		err := m.apply(ctx, status.Migration)
+		if err != nil {
+			return result, fmt.Errorf("migrate: apply version %d: %w", status.Version, err)
+		}
+		result = append(result, status.Migration)
+	}
+	return result, nil
+}
+
+// apply runs the statements and inserts the version in a transaction.
+// With MySQL, DDL statements are committed implicitly, so the migrations should be idempotent
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	statements, err := m.renderStatements(migration)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range statements {
+		_, err := tx.ExecContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := tx.Rebind(fmt.Sprintf(
+		`INSERT INTO %s (version, description) VALUES (?, ?)`,
+		m.tableNames.SchemaVersionTableName(),
+	))
+	_, err = tx.ExecContext(ctx, query, migration.Version, migration.Description)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Migrator) renderStatements(migration Migration) ([]string, error) {
+	tmpl, err := template.New(strconv.Itoa(migration.Version)).Option("missingkey=error").Parse(migration.SQL)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, m.tableNames)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitStatements(buf.String()), nil
+}
+
+// splitStatements splits by semicolons, the statements must not contain semicolons in string literals
+func splitStatements(data string) []string {
+	var result []string
+	for _, stmt := range strings.Split(data, ";") {
+		stmt = strings.TrimSpace(stmt)
+		if len(stmt) > 0 {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	db := sqlx.MustConnect("sqlite3", filepath.Join(t.TempDir(), "cache_inv.db"))
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+var testTableNames = TableNames{
+	EventTableName:  "events",
+	OffsetTableName: "offsets",
+}
+
+var testMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create tables",
+		SQL: `
+CREATE TABLE {{.EventTableName}} (id INTEGER PRIMARY KEY, data TEXT NOT NULL);
+CREATE TABLE {{.OffsetTableName}} (server_name VARCHAR(100) PRIMARY KEY, last_seq INTEGER NOT NULL);
+`,
+	},
+	{
+		Version:     2,
+		Description: "add seq",
+		SQL:         `ALTER TABLE {{.EventTableName}} ADD COLUMN seq INTEGER NULL`,
+	},
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0002_add_seq.sql":       {Data: []byte("ALTER TABLE")},
+			"migrations/0001_create_tables.sql": {Data: []byte("CREATE TABLE")},
+			"migrations/README.md":              {Data: []byte("readme")},
+		}
+
+		migrations, err := Load(fsys, "migrations")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []Migration{
+			{Version: 1, Description: "create tables", SQL: "CREATE TABLE"},
+			{Version: 2, Description: "add seq", SQL: "ALTER TABLE"},
+		}, migrations)
+	})
+
+	t.Run("invalid file name", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/create_tables.sql": {Data: []byte("CREATE TABLE")},
+		}
+
+		_, err := Load(fsys, "migrations")
+		assert.Equal(t, true, errors.Is(err, ErrInvalidMigrationFile))
+		assert.Equal(t, "migrate: invalid migration file name: 'create_tables.sql'", err.Error())
+	})
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("up and status", func(t *testing.T) {
+		db := newTestDB(t)
+
+		m := New(db, testMigrations[:1], testTableNames)
+
+		statuses, err := m.Status(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(statuses))
+		assert.Equal(t, false, statuses[0].Applied)
+
+		applied, err := m.Up(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, testMigrations[:1], applied)
+
+		// add new migration
+		m = New(db, testMigrations, testTableNames)
+
+		applied, err = m.Up(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, testMigrations[1:], applied)
+
+		applied, err = m.Up(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 0, len(applied))
+
+		statuses, err = m.Status(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, len(statuses))
+		assert.Equal(t, true, statuses[0].Applied)
+		assert.Equal(t, true, statuses[1].Applied)
+		assert.Equal(t, false, statuses[1].AppliedAt.IsZero())
+
+		db.MustExec(`INSERT INTO events (id, data, seq) VALUES (1, 'key01', 11)`)
+		db.MustExec(`INSERT INTO offsets (server_name, last_seq) VALUES ('redis:11', 11)`)
+
+		var count int
+		err = db.Get(&count, `SELECT COUNT(*) FROM events_schema_version`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, count)
+	})
+
+	t.Run("failed migration not recorded", func(t *testing.T) {
+		db := newTestDB(t)
+
+		m := New(db, []Migration{
+			testMigrations[0],
+			{Version: 2, Description: "invalid", SQL: `ALTER TABLE not_existed ADD COLUMN seq INTEGER`},
+		}, testTableNames)
+
+		applied, err := m.Up(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, testMigrations[:1], applied)
+
+		statuses, err := m.Status(ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, statuses[0].Applied)
+		assert.Equal(t, false, statuses[1].Applied)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		db := newTestDB(t)
+
+		m := New(db, []Migration{
+			{Version: 1, Description: "invalid", SQL: `CREATE TABLE {{.AnotherTableName}} (id INTEGER)`},
+		}, testTableNames)
+
+		_, err := m.Up(ctx)
+		assert.Error(t, err)
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	assert.Equal(t, []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}, splitStatements(`
+CREATE TABLE a (id INT);
+
+CREATE TABLE b (id INT);
+`))
+	assert.Equal(t, []string(nil), splitStatements(" ; \n"))
+}
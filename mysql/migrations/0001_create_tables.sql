@@ -1,4 +1,4 @@
-CREATE TABLE IF NOT EXISTS `invalidate_events`
+CREATE TABLE IF NOT EXISTS `{{.EventTableName}}`
 (
     `id`         BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
     `seq`        BIGINT UNSIGNED NULL,
@@ -7,7 +7,7 @@ CREATE TABLE IF NOT EXISTS `invalidate_events`
     `updated_at` TIMESTAMP       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 
-CREATE TABLE IF NOT EXISTS `invalidate_offsets`
+CREATE TABLE IF NOT EXISTS `{{.OffsetTableName}}`
 (
     `server_name` VARCHAR(100)    NOT NULL PRIMARY KEY,
     `last_seq`    BIGINT UNSIGNED NOT NULL,
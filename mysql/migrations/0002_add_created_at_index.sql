@@ -0,0 +1,17 @@
+-- MySQL does not support CREATE INDEX IF NOT EXISTS, the index is only created if it does not exist,
+-- so the migration can be run again after a failure
+SET @create_index_stmt = (
+    SELECT IF(
+        COUNT(*) = 0,
+        'CREATE INDEX `idx_created_at` ON `{{.EventTableName}}` (`created_at`, `id`)',
+        'DO 0'
+    )
+    FROM information_schema.statistics
+    WHERE table_schema = DATABASE()
+      AND table_name = '{{.EventTableName}}'
+      AND index_name = 'idx_created_at'
+);
+
+PREPARE create_index_stmt FROM @create_index_stmt;
+EXECUTE create_index_stmt;
+DEALLOCATE PREPARE create_index_stmt;
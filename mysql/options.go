@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"github.com/jmoiron/sqlx"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+type repoConfig struct {
+	partitionSize    uint64
+	futurePartitions int
+
+	replica *sqlx.DB
+
+	pipelineName string
+}
+
+func newRepoConfig(options []Option) repoConfig {
+	conf := repoConfig{
+		pipelineName: cacheinv.DefaultPipelineName,
+	}
+
+	for _, fn := range options {
+		fn(&conf)
+	}
+
+	return conf
+}
+
+// Option ...
+type Option func(conf *repoConfig)
+
+// WithPartitionRetention enables the partition-based retention, the event table must be range-partitioned by id.
+// Instead of deleting rows, DeleteEventsBefore drops whole partitions having only older events,
+// and RunMaintenance pre-creates partitions of *partitionSize* ids,
+// at least *futurePartitions* partitions are kept ahead of the current max id
+func WithPartitionRetention(partitionSize uint64, futurePartitions int) Option {
+	return func(conf *repoConfig) {
+		conf.partitionSize = partitionSize
+		conf.futurePartitions = futurePartitions
+	}
+}
+
+// WithReadReplica makes GetEventsFrom (used by the consumers of cache servers) read from *replica*.
+// The reads fall back to the primary when the replica has not caught up with the sequence numbers
+// already published by the runner. Other methods, including GetLastEvents, always use the primary
+func WithReadReplica(replica *sqlx.DB) Option {
+	return func(conf *repoConfig) {
+		conf.replica = replica
+	}
+}
+
+// WithPipelineName configures the value of the label 'pipeline' of the metrics of the repository,
+// default is cacheinv.DefaultPipelineName
+func WithPipelineName(name string) Option {
+	return func(conf *repoConfig) {
+		conf.pipelineName = name
+	}
+}
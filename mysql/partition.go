@@ -0,0 +1,212 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var eventPartitionsTotal = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "event_partitions_total",
+	Help: "number of partitions of the event table, when using the partition-based retention",
+}, []string{"pipeline"})
+
+var eventDroppedPartitionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "event_dropped_partitions_total",
+	Help: "number of partitions of the event table dropped by retention",
+}, []string{"pipeline"})
+
+// ErrTableNotPartitioned is returned when the partition-based retention is enabled,
+// but the event table is not partitioned
+var ErrTableNotPartitioned = errors.New("mysql: event table is not partitioned")
+
+// ErrMaxValuePartitionMissing is returned when the event table has no partition with VALUES LESS THAN MAXVALUE,
+// without it, inserting events with ids after the last partition fails
+var ErrMaxValuePartitionMissing = errors.New("mysql: event table has no partition with VALUES LESS THAN MAXVALUE")
+
+// partitionInfo is a partition of the event table, containing events with ids < upperBound
+type partitionInfo struct {
+	name       string
+	upperBound uint64
+	maxValue   bool
+}
+
+func (r *repoImpl) getPartitions(ctx context.Context) ([]partitionInfo, error) {
+	query := `
+SELECT PARTITION_NAME, PARTITION_DESCRIPTION FROM information_schema.PARTITIONS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?
+ORDER BY PARTITION_ORDINAL_POSITION
+`
+	rows, err := r.db.QueryContext(ctx, query, r.eventTableName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var result []partitionInfo
+	for rows.Next() {
+		var name sql.NullString
+		var desc sql.NullString
+
+		err := rows.Scan(&name, &desc)
+		if err != nil {
+			return nil, err
+		}
+		if !name.Valid {
+			return nil, ErrTableNotPartitioned
+		}
+
+		p := partitionInfo{name: name.String}
+		if desc.String == "MAXVALUE" {
+			p.maxValue = true
+		} else {
+			p.upperBound, err = strconv.ParseUint(desc.String, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("mysql: invalid partition description '%s': %w", desc.String, err)
+			}
+		}
+		result = append(result, p)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, ErrTableNotPartitioned
+	}
+
+	eventPartitionsTotal.WithLabelValues(r.conf.pipelineName).Set(float64(len(result)))
+	return result, nil
+}
+
+// partitionsToDrop returns the names of the partitions containing only events with ids < *beforeID*
+func partitionsToDrop(partitions []partitionInfo, beforeID uint64) []string {
+	var result []string
+	for _, p := range partitions {
+		if p.maxValue || p.upperBound > beforeID {
+			continue
+		}
+		result = append(result, p.name)
+	}
+	return result
+}
+
+// partitionBoundsToCreate returns the upper bounds of the new partitions, such that
+// at least *futurePartitions* partitions are after the partition containing *maxID*
+func partitionBoundsToCreate(
+	partitions []partitionInfo, maxID uint64, partitionSize uint64, futurePartitions int,
+) []uint64 {
+	var lastBound uint64
+	for _, p := range partitions {
+		if !p.maxValue && p.upperBound > lastBound {
+			lastBound = p.upperBound
+		}
+	}
+
+	target := (maxID/partitionSize + 1 + uint64(futurePartitions)) * partitionSize
+
+	bound := lastBound
+	if bound == 0 {
+		bound = (maxID/partitionSize + 1) * partitionSize
+	} else {
+		bound += partitionSize
+	}
+
+	var result []uint64
+	for ; bound <= target; bound += partitionSize {
+		result = append(result, bound)
+	}
+	return result
+}
+
+func partitionName(upperBound uint64) string {
+	return fmt.Sprintf("p%d", upperBound)
+}
+
+// findMaxValuePartition returns the name of the partition for ids not covered by the other partitions
+func findMaxValuePartition(partitions []partitionInfo) (string, bool) {
+	for _, p := range partitions {
+		if p.maxValue {
+			return p.name, true
+		}
+	}
+	return "", false
+}
+
+// dropPartitionsBefore drops the partitions containing only events with ids < *beforeID*
+func (r *repoImpl) dropPartitionsBefore(ctx context.Context, beforeID uint64) error {
+	partitions, err := r.getPartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	names := partitionsToDrop(partitions, beforeID)
+	if len(names) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf("ALTER TABLE `%s` DROP PARTITION %s", r.eventTableName, strings.Join(names, ", "))
+	_, err = r.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	eventDroppedPartitionsTotal.WithLabelValues(r.conf.pipelineName).Add(float64(len(names)))
+	eventPartitionsTotal.WithLabelValues(r.conf.pipelineName).Set(float64(len(partitions) - len(names)))
+	return nil
+}
+
+// RunMaintenance pre-creates the partitions of the event table when using the partition-based retention.
+// The new partitions are split from the partition with VALUES LESS THAN MAXVALUE, which is required
+func (r *repoImpl) RunMaintenance(ctx context.Context) error {
+	if r.conf.partitionSize == 0 {
+		return nil
+	}
+
+	partitions, err := r.getPartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	maxValueName, ok := findMaxValuePartition(partitions)
+	if !ok {
+		return ErrMaxValuePartitionMissing
+	}
+
+	var maxID uint64
+	err = r.db.GetContext(ctx, &maxID, fmt.Sprintf("SELECT COALESCE(MAX(id), 0) FROM %s", r.eventTableName))
+	if err != nil {
+		return err
+	}
+
+	bounds := partitionBoundsToCreate(partitions, maxID, r.conf.partitionSize, r.conf.futurePartitions)
+	if len(bounds) == 0 {
+		return nil
+	}
+
+	definitions := make([]string, 0, len(bounds)+1)
+	for _, bound := range bounds {
+		definitions = append(definitions,
+			fmt.Sprintf("PARTITION %s VALUES LESS THAN (%d)", partitionName(bound), bound),
+		)
+	}
+	definitions = append(definitions, fmt.Sprintf("PARTITION %s VALUES LESS THAN MAXVALUE", maxValueName))
+
+	query := fmt.Sprintf("ALTER TABLE `%s` REORGANIZE PARTITION %s INTO (%s)",
+		r.eventTableName, maxValueName, strings.Join(definitions, ", "))
+
+	_, err = r.db.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	eventPartitionsTotal.WithLabelValues(r.conf.pipelineName).Set(float64(len(partitions) + len(bounds)))
+	return nil
+}
@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionsToDrop(t *testing.T) {
+	partitions := []partitionInfo{
+		{name: "p10", upperBound: 10},
+		{name: "p20", upperBound: 20},
+		{name: "p30", upperBound: 30},
+		{name: "p_max", maxValue: true},
+	}
+
+	assert.Equal(t, []string(nil), partitionsToDrop(partitions, 9))
+	assert.Equal(t, []string{"p10"}, partitionsToDrop(partitions, 10))
+	assert.Equal(t, []string{"p10"}, partitionsToDrop(partitions, 19))
+	assert.Equal(t, []string{"p10", "p20"}, partitionsToDrop(partitions, 25))
+	assert.Equal(t, []string{"p10", "p20", "p30"}, partitionsToDrop(partitions, 100))
+}
+
+func TestPartitionBoundsToCreate(t *testing.T) {
+	t.Run("only max value partition", func(t *testing.T) {
+		partitions := []partitionInfo{
+			{name: "p_max", maxValue: true},
+		}
+		assert.Equal(t, []uint64{10, 20, 30}, partitionBoundsToCreate(partitions, 0, 10, 2))
+		assert.Equal(t, []uint64{30, 40, 50}, partitionBoundsToCreate(partitions, 25, 10, 2))
+	})
+
+	t.Run("enough partitions", func(t *testing.T) {
+		partitions := []partitionInfo{
+			{name: "p10", upperBound: 10},
+			{name: "p20", upperBound: 20},
+			{name: "p30", upperBound: 30},
+			{name: "p_max", maxValue: true},
+		}
+		assert.Equal(t, []uint64(nil), partitionBoundsToCreate(partitions, 9, 10, 2))
+	})
+
+	t.Run("add partitions", func(t *testing.T) {
+		partitions := []partitionInfo{
+			{name: "p10", upperBound: 10},
+			{name: "p20", upperBound: 20},
+			{name: "p30", upperBound: 30},
+		}
+		assert.Equal(t, []uint64{40}, partitionBoundsToCreate(partitions, 10, 10, 2))
+		assert.Equal(t, []uint64{40, 50, 60}, partitionBoundsToCreate(partitions, 35, 10, 2))
+	})
+}
@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+var replicaReadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "mysql_replica_reads_total",
+	Help: "number of GetEventsFrom calls using the read replica, by result: 'replica' or 'fallback' (to the primary)",
+}, []string{"pipeline", "result"})
+
+// updatePublishedSeq records the max sequence number already written to the primary,
+// events up to this sequence number must be returned by GetEventsFrom
+func (r *repoImpl) updatePublishedSeq(seq int64) {
+	for {
+		current := r.publishedSeq.Load()
+		if seq <= current || r.publishedSeq.CompareAndSwap(current, seq) {
+			return
+		}
+	}
+}
+
+// getEventsFromReplica reads from the replica, and falls back to the primary
+// when the replica returned an error or has not caught up with the published sequence number
+func (r *repoImpl) getEventsFromReplica(
+	ctx context.Context, from uint64, limit uint64,
+) ([]cacheinv.InvalidateEvent, error) {
+	// must be loaded before reading from the replica
+	publishedSeq := r.publishedSeq.Load()
+
+	result, err := r.selectEventsFrom(ctx, r.conf.replica, from, limit)
+	if err == nil && replicaCaughtUp(result, from, limit, publishedSeq) {
+		replicaReadsTotal.WithLabelValues(r.conf.pipelineName, "replica").Inc()
+		return result, nil
+	}
+
+	replicaReadsTotal.WithLabelValues(r.conf.pipelineName, "fallback").Inc()
+	return r.selectEventsFrom(ctx, r.db, from, limit)
+}
+
+// replicaCaughtUp checks whether *events* read from the replica contains all events
+// with sequence numbers in [from, from + limit) that were already published.
+// Sequence numbers are continuous, so a full batch is always complete
+func replicaCaughtUp(events []cacheinv.InvalidateEvent, from uint64, limit uint64, publishedSeq int64) bool {
+	if uint64(len(events)) >= limit {
+		return true
+	}
+
+	lastSeq := int64(from) - 1
+	if len(events) > 0 {
+		lastSeq = events[len(events)-1].Seq.Int64
+	}
+	return lastSeq >= publishedSeq
+}
@@ -0,0 +1,23 @@
+package mysql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+func TestReplicaCaughtUp(t *testing.T) {
+	events := []cacheinv.InvalidateEvent{
+		{ID: 1, Seq: newInt64(5)},
+		{ID: 2, Seq: newInt64(6)},
+	}
+
+	assert.Equal(t, true, replicaCaughtUp(events, 5, 2, 10))
+	assert.Equal(t, true, replicaCaughtUp(events, 5, 3, 6))
+	assert.Equal(t, false, replicaCaughtUp(events, 5, 3, 7))
+
+	assert.Equal(t, true, replicaCaughtUp(nil, 5, 3, 4))
+	assert.Equal(t, false, replicaCaughtUp(nil, 5, 3, 5))
+}
@@ -6,29 +6,39 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync/atomic"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 
 	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/internal/dbmetrics"
 )
 
 type repoImpl struct {
+	conf repoConfig
+
 	db              *sqlx.DB
 	eventTableName  string
 	offsetTableName string
+
+	publishedSeq atomic.Int64
 }
 
 var _ cacheinv.Repository = &repoImpl{}
+var _ cacheinv.AgeRetentionRepository = &repoImpl{}
+var _ cacheinv.MaintenanceRepository = &repoImpl{}
 
 // NewRepository ...
 func NewRepository(
 	db *sqlx.DB,
 	eventTableName string,
 	offsetTableName string,
+	options ...Option,
 ) cacheinv.Repository {
 	return &repoImpl{
+		conf: newRepoConfig(options),
+
 		db:              db,
 		eventTableName:  eventTableName,
 		offsetTableName: offsetTableName,
@@ -55,7 +65,9 @@ ORDER BY seq DESC LIMIT ?
 	})
 
 	if len(result) > 0 {
-		eventLastUpdatedSeq.Set(float64(result[len(result)-1].GetSequence()))
+		lastSeq := result[len(result)-1].Seq.Int64
+		dbmetrics.EventLastUpdatedSeq.WithLabelValues(r.conf.pipelineName).Set(float64(lastSeq))
+		r.updatePublishedSeq(lastSeq)
 	}
 
 	return result, nil
@@ -77,28 +89,28 @@ ORDER BY id LIMIT ?
 
 // GetEventsFrom returns list of events with sequence number >= *from*
 // in ascending order of event sequence numbers, ignoring events with null sequence numbers
-// size of the list is limited by *limit*
+// size of the list is limited by *limit*.
+// With WithReadReplica, the events are read from the replica if it has caught up
 func (r *repoImpl) GetEventsFrom(ctx context.Context, from uint64, limit uint64) ([]cacheinv.InvalidateEvent, error) {
+	if r.conf.replica != nil {
+		return r.getEventsFromReplica(ctx, from, limit)
+	}
+	return r.selectEventsFrom(ctx, r.db, from, limit)
+}
+
+func (r *repoImpl) selectEventsFrom(
+	ctx context.Context, db *sqlx.DB, from uint64, limit uint64,
+) ([]cacheinv.InvalidateEvent, error) {
 	query := fmt.Sprintf(`
 SELECT id, seq, data FROM %s
 WHERE seq >= ?
 ORDER BY seq LIMIT ?
 `, r.eventTableName)
 	var result []cacheinv.InvalidateEvent
-	err := r.db.SelectContext(ctx, &result, query, from, limit)
+	err := db.SelectContext(ctx, &result, query, from, limit)
 	return result, err
 }
 
-var eventLastUpdatedSeq = promauto.NewGauge(prometheus.GaugeOpts{
-	Name: "event_last_updated_seq",
-	Help: "sequence number of the last updated invalidate_events",
-})
-
-var eventMinRemainingSeq = promauto.NewGauge(prometheus.GaugeOpts{
-	Name: "event_min_remaining_seq",
-	Help: "smallest sequence number after retention",
-})
-
 // UpdateSequences updates only sequence numbers of *events*
 func (r *repoImpl) UpdateSequences(ctx context.Context, events []cacheinv.InvalidateEvent) error {
 	if len(events) == 0 {
@@ -112,7 +124,9 @@ ON DUPLICATE KEY UPDATE seq = IF(seq IS NULL, VALUES(seq), 'error')
 `, r.eventTableName)
 	_, err := r.db.NamedExecContext(ctx, query, events)
 	if err == nil {
-		eventLastUpdatedSeq.Set(float64(events[len(events)-1].GetSequence()))
+		lastSeq := events[len(events)-1].Seq.Int64
+		dbmetrics.EventLastUpdatedSeq.WithLabelValues(r.conf.pipelineName).Set(float64(lastSeq))
+		r.updatePublishedSeq(lastSeq)
 	}
 	return err
 }
@@ -124,12 +138,13 @@ func (r *repoImpl) GetMinSequence(ctx context.Context) (sql.NullInt64, error) {
 	var result sql.NullInt64
 	err := r.db.GetContext(ctx, &result, query)
 	if result.Valid {
-		eventMinRemainingSeq.Set(float64(result.Int64))
+		dbmetrics.EventMinRemainingSeq.WithLabelValues(r.conf.pipelineName).Set(float64(result.Int64))
 	}
 	return result, err
 }
 
-// DeleteEventsBefore deletes events with sequence number < *beforeSeq*
+// DeleteEventsBefore deletes events with sequence number < *beforeSeq*.
+// With the partition-based retention, only events of the partitions having no newer events are deleted
 func (r *repoImpl) DeleteEventsBefore(ctx context.Context, beforeSeq uint64) error {
 	query := fmt.Sprintf(`SELECT id FROM %s WHERE seq = ?`, r.eventTableName)
 	var selectedID int64
@@ -141,13 +156,37 @@ func (r *repoImpl) DeleteEventsBefore(ctx context.Context, beforeSeq uint64) err
 		return err
 	}
 
+	if r.conf.partitionSize > 0 {
+		return r.dropPartitionsBefore(ctx, uint64(selectedID))
+	}
+
 	_, err = r.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROm %s WHERE id < ?`, r.eventTableName), selectedID)
 	if err == nil {
-		eventMinRemainingSeq.Set(float64(beforeSeq))
+		dbmetrics.EventMinRemainingSeq.WithLabelValues(r.conf.pipelineName).Set(float64(beforeSeq))
 	}
 	return err
 }
 
+// GetLatestSequenceOlderThan returns the sequence number of the latest created event
+// among events created more than *maxAge* ago (except events with null sequence numbers),
+// returns null if no such events existed
+func (r *repoImpl) GetLatestSequenceOlderThan(ctx context.Context, maxAge time.Duration) (sql.NullInt64, error) {
+	query := fmt.Sprintf(`
+SELECT seq FROM %s
+WHERE created_at < NOW() - INTERVAL ? SECOND AND seq IS NOT NULL
+ORDER BY created_at DESC, id DESC LIMIT 1
+`, r.eventTableName)
+	var result sql.NullInt64
+	err := r.db.GetContext(ctx, &result, query, int64(maxAge/time.Second))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return sql.NullInt64{}, nil
+		}
+		return sql.NullInt64{}, err
+	}
+	return result, nil
+}
+
 // InvalidateOffset ...
 type InvalidateOffset struct {
 	ServerName string `db:"server_name"`
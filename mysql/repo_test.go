@@ -3,11 +3,11 @@ package mysql
 import (
 	"context"
 	"database/sql"
-	_ "embed"
 	"fmt"
 	"math/rand"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/QuangTung97/eventx/helpers"
 	_ "github.com/go-sql-driver/mysql"
@@ -15,6 +15,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/migrate"
+	"github.com/QuangTung97/cacheinv/repotest"
 )
 
 type repoTest struct {
@@ -32,14 +34,17 @@ func initDB() *sqlx.DB {
 			"mysql",
 			"root:1@tcp(localhost:3306)/cache_inv?parseTime=true&multiStatements=true",
 		)
-		globalDB.MustExec(migrateSQL)
+		_, err := migrate.New(globalDB, Migrations(), migrate.TableNames{
+			EventTableName:  "invalidate_events",
+			OffsetTableName: "invalidate_offsets",
+		}).Up(context.Background())
+		if err != nil {
+			panic(err)
+		}
 	})
 	return globalDB
 }
 
-//go:embed migration.sql
-var migrateSQL string
-
 func newRepoTest() *repoTest {
 	db := initDB()
 
@@ -64,6 +69,24 @@ VALUES (:data)
 	}
 }
 
+func (r *repoTest) insertData(dataList ...string) {
+	events := make([]cacheinv.InvalidateEvent, 0, len(dataList))
+	for _, data := range dataList {
+		events = append(events, cacheinv.InvalidateEvent{Data: data})
+	}
+	r.insertEvents(events...)
+}
+
+func (r *repoTest) insertDataWithAge(age time.Duration, dataList ...string) {
+	query := `
+INSERT INTO invalidate_events (data, created_at)
+VALUES (?, NOW() - INTERVAL ? SECOND)
+`
+	for _, data := range dataList {
+		r.db.MustExec(query, data, int64(age/time.Second))
+	}
+}
+
 func newInt64(v int64) sql.NullInt64 {
 	return sql.NullInt64{
 		Valid: true,
@@ -71,6 +94,19 @@ func newInt64(v int64) sql.NullInt64 {
 	}
 }
 
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Fixture {
+		r := newRepoTest()
+		return repotest.Fixture{
+			Repo: r.repo,
+			InsertEvents: func(dataList ...string) {
+				r.insertData(dataList...)
+			},
+			InsertEventsWithAge: r.insertDataWithAge,
+		}
+	})
+}
+
 func TestRepo_Eventx_Repo(t *testing.T) {
 	t.Run("normal", func(t *testing.T) {
 		r := newRepoTest()
@@ -385,3 +421,196 @@ func TestRepo_Repo_WithError(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+type partitionRepoTest struct {
+	ctx  context.Context
+	db   *sqlx.DB
+	repo cacheinv.Repository
+}
+
+func newPartitionRepoTest() *partitionRepoTest {
+	db := initDB()
+
+	db.MustExec(`DROP TABLE IF EXISTS partitioned_events`)
+	db.MustExec(`CREATE TABLE partitioned_events LIKE invalidate_events`)
+	db.MustExec(`
+ALTER TABLE partitioned_events PARTITION BY RANGE (id) (
+    PARTITION p_max VALUES LESS THAN MAXVALUE
+)`)
+
+	return &partitionRepoTest{
+		ctx:  context.Background(),
+		db:   db,
+		repo: NewRepository(db, "partitioned_events", "invalidate_offsets", WithPartitionRetention(10, 2)),
+	}
+}
+
+func (r *partitionRepoTest) getPartitionNames() []string {
+	var names []string
+	err := r.db.Select(&names, `
+SELECT PARTITION_NAME FROM information_schema.PARTITIONS
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'partitioned_events'
+ORDER BY PARTITION_ORDINAL_POSITION
+`)
+	if err != nil {
+		panic(err)
+	}
+	return names
+}
+
+func TestRepo_PartitionRetention(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		r := newPartitionRepoTest()
+
+		maintenanceRepo, ok := r.repo.(cacheinv.MaintenanceRepository)
+		assert.Equal(t, true, ok)
+
+		err := maintenanceRepo.RunMaintenance(r.ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"p10", "p20", "p30", "p_max"}, r.getPartitionNames())
+
+		// run again without changes
+		err = maintenanceRepo.RunMaintenance(r.ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"p10", "p20", "p30", "p_max"}, r.getPartitionNames())
+
+		events := make([]cacheinv.InvalidateEvent, 0, 25)
+		for i := 0; i < 25; i++ {
+			r.db.MustExec(`INSERT INTO partitioned_events (data) VALUES ('key01')`)
+			events = append(events, cacheinv.InvalidateEvent{
+				ID:   int64(i + 1),
+				Seq:  newInt64(int64(i + 1)),
+				Data: "key01",
+			})
+		}
+		err = r.repo.UpdateSequences(r.ctx, events)
+		assert.Equal(t, nil, err)
+
+		err = maintenanceRepo.RunMaintenance(r.ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"p10", "p20", "p30", "p40", "p50", "p_max"}, r.getPartitionNames())
+
+		// partition p30 still contains events 20 -> 25
+		err = r.repo.DeleteEventsBefore(r.ctx, 23)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"p30", "p40", "p50", "p_max"}, r.getPartitionNames())
+
+		minSeq, err := r.repo.GetMinSequence(r.ctx)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, newInt64(20), minSeq)
+
+		// not existed sequence
+		err = r.repo.DeleteEventsBefore(r.ctx, 100)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"p30", "p40", "p50", "p_max"}, r.getPartitionNames())
+	})
+
+	t.Run("not partitioned", func(t *testing.T) {
+		r := newRepoTest()
+		repo := NewRepository(r.db, "invalidate_events", "invalidate_offsets", WithPartitionRetention(10, 2))
+
+		maintenanceRepo, ok := repo.(cacheinv.MaintenanceRepository)
+		assert.Equal(t, true, ok)
+
+		err := maintenanceRepo.RunMaintenance(r.ctx)
+		assert.Equal(t, ErrTableNotPartitioned, err)
+	})
+
+	t.Run("missing max value partition", func(t *testing.T) {
+		r := newPartitionRepoTest()
+		r.db.MustExec(`
+ALTER TABLE partitioned_events PARTITION BY RANGE (id) (
+    PARTITION p10 VALUES LESS THAN (10)
+)`)
+
+		maintenanceRepo, ok := r.repo.(cacheinv.MaintenanceRepository)
+		assert.Equal(t, true, ok)
+
+		err := maintenanceRepo.RunMaintenance(r.ctx)
+		assert.Equal(t, ErrMaxValuePartitionMissing, err)
+		assert.Equal(t, []string{"p10"}, r.getPartitionNames())
+	})
+}
+
+// newReplicaRepoTest uses the database cache_inv_replica as the read replica,
+// events are copied manually to simulate the replication lag
+func newReplicaRepoTest() *repoTest {
+	r := newRepoTest()
+
+	r.db.MustExec(`CREATE DATABASE IF NOT EXISTS cache_inv_replica`)
+	r.db.MustExec(`CREATE TABLE IF NOT EXISTS cache_inv_replica.invalidate_events LIKE invalidate_events`)
+	r.db.MustExec(`TRUNCATE cache_inv_replica.invalidate_events`)
+
+	replica := sqlx.MustConnect("mysql", "root:1@tcp(localhost:3306)/cache_inv_replica?parseTime=true")
+	r.repo = NewRepository(r.db, "invalidate_events", "invalidate_offsets", WithReadReplica(replica))
+
+	return r
+}
+
+func TestRepo_ReadReplica(t *testing.T) {
+	r := newReplicaRepoTest()
+
+	r.insertData("key01", "key02", "key03")
+	err := r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{
+		{ID: 1, Seq: newInt64(1)},
+		{ID: 2, Seq: newInt64(2)},
+		{ID: 3, Seq: newInt64(3)},
+	})
+	assert.Equal(t, nil, err)
+
+	r.db.MustExec(`
+INSERT INTO cache_inv_replica.invalidate_events (id, seq, data)
+SELECT id, seq, CONCAT('replica-', data) FROM invalidate_events WHERE seq <= 2
+`)
+
+	// full batch from the replica
+	events, err := r.repo.GetEventsFrom(r.ctx, 1, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{
+		{ID: 1, Seq: newInt64(1), Data: "replica-key01"},
+		{ID: 2, Seq: newInt64(2), Data: "replica-key02"},
+	}, events)
+
+	// replica is behind, fall back to the primary
+	events, err = r.repo.GetEventsFrom(r.ctx, 2, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{
+		{ID: 2, Seq: newInt64(2), Data: "key02"},
+		{ID: 3, Seq: newInt64(3), Data: "key03"},
+	}, events)
+
+	r.db.MustExec(`
+INSERT INTO cache_inv_replica.invalidate_events (id, seq, data)
+SELECT id, seq, CONCAT('replica-', data) FROM invalidate_events WHERE seq = 3
+`)
+
+	events, err = r.repo.GetEventsFrom(r.ctx, 2, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{
+		{ID: 2, Seq: newInt64(2), Data: "replica-key02"},
+		{ID: 3, Seq: newInt64(3), Data: "replica-key03"},
+	}, events)
+}
+
+func TestMigrations_RunAgain(t *testing.T) {
+	db := initDB()
+
+	// simulate a crash after the index was created but before the version was inserted
+	db.MustExec(`DELETE FROM invalidate_events_schema_version WHERE version = 2`)
+
+	applied, err := migrate.New(db, Migrations(), migrate.TableNames{
+		EventTableName:  "invalidate_events",
+		OffsetTableName: "invalidate_offsets",
+	}).Up(context.Background())
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(applied))
+	assert.Equal(t, 2, applied[0].Version)
+
+	var count int
+	err = db.Get(&count, `
+SELECT COUNT(DISTINCT index_name) FROM information_schema.statistics
+WHERE table_schema = DATABASE() AND table_name = 'invalidate_events' AND index_name = 'idx_created_at'
+`)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, count)
+}
@@ -1,6 +1,9 @@
 package cacheinv
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/QuangTung97/eventx"
 )
 
@@ -8,21 +11,74 @@ type jobConfig struct {
 	runnerOptions    []eventx.Option
 	retryOptions     []eventx.RetryConsumerOption
 	retentionOptions []eventx.RetentionOption
+
+	retryErrorLogger func(err error)
+
+	doubleDeleteDelay          time.Duration
+	doubleDeleteMaxPendingKeys int
+
+	serverGroups map[int64][]string
+
+	defaultOperation Operation
+	expireTTL        time.Duration
+
+	retentionMode               RetentionMode
+	retentionMaxAge             time.Duration
+	ageRetentionCheckInterval   time.Duration
+	ageRetentionDeleteBatchSize uint64
+
+	maintenanceInterval time.Duration
+
+	consumerAwareRetention bool
+	retentionHardCap       uint64
+
+	gapRecoveryAction         GapRecoveryAction
+	gapRecoveryWebhookURL     string
+	gapRecoveryWebhookTimeout time.Duration
+
+	pipelineName string
 }
 
 func newJobConfig(options []Option) jobConfig {
 	conf := jobConfig{
 		runnerOptions:    nil,
 		retentionOptions: nil,
+		serverGroups:     map[int64][]string{},
+		defaultOperation: OperationDelete,
+		expireTTL:        10 * time.Second,
+
+		retentionMode:               RetentionModeSize,
+		ageRetentionCheckInterval:   time.Minute,
+		ageRetentionDeleteBatchSize: 1000,
+
+		maintenanceInterval: time.Minute,
+
+		gapRecoveryWebhookTimeout: 10 * time.Second,
+
+		pipelineName: DefaultPipelineName,
 	}
 
 	for _, fn := range options {
 		fn(&conf)
 	}
 
+	validateJobConfig(conf)
+
 	return conf
 }
 
+func validateJobConfig(conf jobConfig) {
+	switch conf.defaultOperation {
+	case OperationDelete, OperationExpire:
+	default:
+		panic(fmt.Sprintf("cacheinv: invalid default operation '%s'", conf.defaultOperation))
+	}
+
+	if conf.doubleDeleteDelay > 0 && conf.doubleDeleteMaxPendingKeys <= 0 {
+		panic("cacheinv: double delete max pending keys must be greater than 0")
+	}
+}
+
 // Option ...
 type Option func(conf *jobConfig)
 
@@ -40,9 +96,126 @@ func WithRetryConsumerOptions(options ...eventx.RetryConsumerOption) Option {
 	}
 }
 
+// WithRetryConsumerErrorLogger configures the error logger of the consumers of cache servers,
+// takes precedence over eventx.WithRetryConsumerErrorLogger in WithRetryConsumerOptions.
+// With WithGapRecovery, the logger is still called for the errors used to detect the gaps
+func WithRetryConsumerErrorLogger(logger func(err error)) Option {
+	return func(conf *jobConfig) {
+		conf.retryErrorLogger = logger
+	}
+}
+
 // WithRetentionOptions ...
 func WithRetentionOptions(options ...eventx.RetentionOption) Option {
 	return func(conf *jobConfig) {
 		conf.retentionOptions = options
 	}
 }
+
+// WithDoubleDelete enables the delayed double delete: after the keys are deleted by DeleteCacheKeys,
+// the same keys are deleted again after *delay*.
+// The delayed deletes are kept in memory, at most *maxPendingKeys* keys for each server,
+// the oldest delayed deletes are dropped when exceeding this limit.
+// A batch with more than *maxPendingKeys* keys is truncated,
+// the dropped keys are counted in double_delete_dropped_keys_total
+func WithDoubleDelete(delay time.Duration, maxPendingKeys int) Option {
+	return func(conf *jobConfig) {
+		conf.doubleDeleteDelay = delay
+		conf.doubleDeleteMaxPendingKeys = maxPendingKeys
+	}
+}
+
+// WithServerGroup adds the servers with *serverIDs* to the server group *name*,
+// events with EventPayload.Groups containing *name* will be applied to these servers
+func WithServerGroup(name string, serverIDs ...int64) Option {
+	return func(conf *jobConfig) {
+		for _, id := range serverIDs {
+			conf.serverGroups[id] = append(conf.serverGroups[id], name)
+		}
+	}
+}
+
+// WithDefaultOperation configures the operation of events in the plain format or without "op",
+// only OperationDelete (the default) and OperationExpire are supported
+func WithDefaultOperation(op Operation) Option {
+	return func(conf *jobConfig) {
+		conf.defaultOperation = op
+	}
+}
+
+// WithExpireTTL configures the default TTL of OperationExpire, default is 10 seconds
+func WithExpireTTL(ttl time.Duration) Option {
+	return func(conf *jobConfig) {
+		conf.expireTTL = ttl
+	}
+}
+
+// RetentionMode ...
+type RetentionMode string
+
+const (
+	// RetentionModeSize keeps at most a number of events, configured by WithRetentionOptions
+	RetentionModeSize RetentionMode = "size"
+	// RetentionModeAge deletes events created more than the max age ago, configured by WithRetentionMaxAge
+	RetentionModeAge RetentionMode = "age"
+	// RetentionModeSizeOrAge deletes events when either the size limit or the max age exceeded
+	RetentionModeSizeOrAge RetentionMode = "size_or_age"
+)
+
+// WithRetentionMode configures how old events are deleted, default is RetentionModeSize.
+// RetentionModeAge and RetentionModeSizeOrAge require the Repository implementing AgeRetentionRepository
+func WithRetentionMode(mode RetentionMode) Option {
+	return func(conf *jobConfig) {
+		conf.retentionMode = mode
+	}
+}
+
+// WithRetentionMaxAge configures the max age of events for RetentionModeAge and RetentionModeSizeOrAge,
+// events are checked every *checkInterval* and deleted in batches of *deleteBatchSize* events.
+// The latest event exceeding the max age is always kept
+func WithRetentionMaxAge(maxAge time.Duration, checkInterval time.Duration, deleteBatchSize uint64) Option {
+	return func(conf *jobConfig) {
+		conf.retentionMaxAge = maxAge
+		conf.ageRetentionCheckInterval = checkInterval
+		conf.ageRetentionDeleteBatchSize = deleteBatchSize
+	}
+}
+
+// WithMaintenanceInterval configures the interval of calling RunMaintenance
+// for repositories implementing MaintenanceRepository, default is 1 minute
+func WithMaintenanceInterval(d time.Duration) Option {
+	return func(conf *jobConfig) {
+		conf.maintenanceInterval = d
+	}
+}
+
+// WithConsumerAwareRetention makes the retention (of all retention modes) never delete events
+// not consumed by all cache servers, cache servers without offsets are ignored.
+// If *hardCap* > 0, events not consumed are still deleted when more than *hardCap* events remained,
+// those deletions are logged and counted in the metric retention_forced_deleted_events_total
+func WithConsumerAwareRetention(hardCap uint64) Option {
+	return func(conf *jobConfig) {
+		conf.consumerAwareRetention = true
+		conf.retentionHardCap = hardCap
+	}
+}
+
+// WithGapRecovery configures the action run when the events after the offset of a cache server
+// were deleted before being consumed, *webhookURL* is only used by GapRecoveryWebhook.
+// After the action succeeded, the offset is moved to right before the min sequence number.
+// When *action* is not GapRecoveryNone, the gaps are detected by the errors of the consumers,
+// so the error logger must be configured by WithRetryConsumerErrorLogger instead of WithRetryConsumerOptions
+func WithGapRecovery(action GapRecoveryAction, webhookURL string) Option {
+	return func(conf *jobConfig) {
+		conf.gapRecoveryAction = action
+		conf.gapRecoveryWebhookURL = webhookURL
+	}
+}
+
+// WithPipelineName configures the value of the label 'pipeline' of all metrics of the job,
+// for running multiple jobs in one process, default is DefaultPipelineName
+func WithPipelineName(name string) Option {
+	return func(conf *jobConfig) {
+		conf.pipelineName = name
+	}
+}
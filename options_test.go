@@ -0,0 +1,44 @@
+package cacheinv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewJobConfig(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		conf := newJobConfig(nil)
+		assert.Equal(t, OperationDelete, conf.defaultOperation)
+	})
+
+	t.Run("default operation expire", func(t *testing.T) {
+		conf := newJobConfig([]Option{WithDefaultOperation(OperationExpire)})
+		assert.Equal(t, OperationExpire, conf.defaultOperation)
+	})
+
+	t.Run("invalid default operation", func(t *testing.T) {
+		assert.PanicsWithValue(t, "cacheinv: invalid default operation 'pattern'", func() {
+			newJobConfig([]Option{WithDefaultOperation(OperationPattern)})
+		})
+		assert.PanicsWithValue(t, "cacheinv: invalid default operation 'unknown'", func() {
+			newJobConfig([]Option{WithDefaultOperation("unknown")})
+		})
+	})
+
+	t.Run("double delete", func(t *testing.T) {
+		conf := newJobConfig([]Option{WithDoubleDelete(time.Second, 100)})
+		assert.Equal(t, time.Second, conf.doubleDeleteDelay)
+		assert.Equal(t, 100, conf.doubleDeleteMaxPendingKeys)
+	})
+
+	t.Run("double delete invalid max pending keys", func(t *testing.T) {
+		assert.PanicsWithValue(t, "cacheinv: double delete max pending keys must be greater than 0", func() {
+			newJobConfig([]Option{WithDoubleDelete(time.Second, 0)})
+		})
+		assert.PanicsWithValue(t, "cacheinv: double delete max pending keys must be greater than 0", func() {
+			newJobConfig([]Option{WithDoubleDelete(time.Second, -1)})
+		})
+	})
+}
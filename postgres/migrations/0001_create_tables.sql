@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS {{.EventTableName}}
+(
+    id         BIGSERIAL PRIMARY KEY,
+    seq        BIGINT      NULL,
+    data       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS {{.EventTableName}}_seq_idx ON {{.EventTableName}} (seq);
+
+CREATE TABLE IF NOT EXISTS {{.OffsetTableName}}
+(
+    server_name VARCHAR(100) NOT NULL PRIMARY KEY,
+    last_seq    BIGINT       NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT NOW()
+);
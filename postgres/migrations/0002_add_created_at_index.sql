@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS {{.EventTableName}}_created_at_idx ON {{.EventTableName}} (created_at, id);
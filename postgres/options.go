@@ -0,0 +1,32 @@
+package postgres
+
+import (
+	"github.com/QuangTung97/cacheinv"
+)
+
+type repoConfig struct {
+	pipelineName string
+}
+
+func newRepoConfig(options []Option) repoConfig {
+	conf := repoConfig{
+		pipelineName: cacheinv.DefaultPipelineName,
+	}
+
+	for _, fn := range options {
+		fn(&conf)
+	}
+
+	return conf
+}
+
+// Option ...
+type Option func(conf *repoConfig)
+
+// WithPipelineName configures the value of the label 'pipeline' of the metrics of the repository,
+// default is cacheinv.DefaultPipelineName
+func WithPipelineName(name string) Option {
+	return func(conf *repoConfig) {
+		conf.pipelineName = name
+	}
+}
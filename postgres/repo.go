@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/internal/sqlrepo"
+)
+
+// ErrSequenceAlreadyUpdated is returned when some of the events not existed or already have sequence numbers
+var ErrSequenceAlreadyUpdated = errors.New("postgres: sequence numbers of events already updated")
+
+var dialect = sqlrepo.Dialect{
+	BindType: sqlx.DOLLAR,
+	Now:      "NOW()",
+
+	CreatedBefore: func(maxAge time.Duration) (string, any) {
+		return "created_at < NOW() - make_interval(secs => ?)", maxAge.Seconds()
+	},
+	UpdateSequences:           updateSequences,
+	ErrSequenceAlreadyUpdated: ErrSequenceAlreadyUpdated,
+}
+
+// NewRepository ...
+func NewRepository(
+	db *sqlx.DB,
+	eventTableName string,
+	offsetTableName string,
+	options ...Option,
+) cacheinv.Repository {
+	conf := newRepoConfig(options)
+	return sqlrepo.NewRepository(db, dialect, eventTableName, offsetTableName, conf.pipelineName)
+}
+
+// updateSequences updates the sequence numbers of all events in a single statement
+func updateSequences(
+	ctx context.Context, tx *sqlx.Tx, eventTableName string, events []cacheinv.InvalidateEvent,
+) (int64, error) {
+	values := make([]string, 0, len(events))
+	args := make([]any, 0, 2*len(events))
+	for i, e := range events {
+		values = append(values, fmt.Sprintf("($%d::BIGINT, $%d::BIGINT)", 2*i+1, 2*i+2))
+		args = append(args, e.ID, e.Seq)
+	}
+
+	query := fmt.Sprintf(`
+UPDATE %s AS e SET seq = v.seq, updated_at = NOW()
+FROM (VALUES %s) AS v (id, seq)
+WHERE e.id = v.id AND e.seq IS NULL
+`, eventTableName, strings.Join(values, ", "))
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/migrate"
+	"github.com/QuangTung97/cacheinv/repotest"
+)
+
+type repoTest struct {
+	ctx  context.Context
+	db   *sqlx.DB
+	repo cacheinv.Repository
+}
+
+var dbOnce sync.Once
+var globalDB *sqlx.DB
+
+func initDB() *sqlx.DB {
+	dbOnce.Do(func() {
+		globalDB = sqlx.MustConnect(
+			"postgres",
+			"postgres://postgres:1@localhost:5432/cache_inv?sslmode=disable",
+		)
+		_, err := migrate.New(globalDB, Migrations(), migrate.TableNames{
+			EventTableName:  "invalidate_events",
+			OffsetTableName: "invalidate_offsets",
+		}).Up(context.Background())
+		if err != nil {
+			panic(err)
+		}
+	})
+	return globalDB
+}
+
+func newRepoTest() *repoTest {
+	db := initDB()
+
+	db.MustExec(`TRUNCATE invalidate_events RESTART IDENTITY`)
+	db.MustExec(`TRUNCATE invalidate_offsets`)
+
+	return &repoTest{
+		ctx:  context.Background(),
+		db:   db,
+		repo: NewRepository(db, "invalidate_events", "invalidate_offsets"),
+	}
+}
+
+func (r *repoTest) insertEvents(events ...cacheinv.InvalidateEvent) {
+	query := `
+INSERT INTO invalidate_events (data)
+VALUES (:data)
+`
+	_, err := r.db.NamedExec(query, events)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r *repoTest) insertData(dataList ...string) {
+	events := make([]cacheinv.InvalidateEvent, 0, len(dataList))
+	for _, data := range dataList {
+		events = append(events, cacheinv.InvalidateEvent{Data: data})
+	}
+	r.insertEvents(events...)
+}
+
+func (r *repoTest) insertDataWithAge(age time.Duration, dataList ...string) {
+	query := `
+INSERT INTO invalidate_events (data, created_at)
+VALUES ($1, NOW() - make_interval(secs => $2))
+`
+	for _, data := range dataList {
+		r.db.MustExec(query, data, age.Seconds())
+	}
+}
+
+func newInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{
+		Valid: true,
+		Int64: v,
+	}
+}
+
+func TestRepo_Conformance(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Fixture {
+		r := newRepoTest()
+		return repotest.Fixture{
+			Repo: r.repo,
+			InsertEvents: func(dataList ...string) {
+				r.insertData(dataList...)
+			},
+			InsertEventsWithAge: r.insertDataWithAge,
+		}
+	})
+}
+
+func TestRepo_UpdateSequences(t *testing.T) {
+	t.Run("update already updated events", func(t *testing.T) {
+		r := newRepoTest()
+
+		e1 := cacheinv.InvalidateEvent{
+			Data: "key01",
+		}
+		e2 := cacheinv.InvalidateEvent{
+			Data: "key02",
+		}
+
+		r.insertEvents(e1, e2)
+
+		e1.ID = 1
+		e2.ID = 2
+
+		e1.Seq = newInt64(11)
+		err := r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{e1})
+		assert.Equal(t, nil, err)
+
+		e1.Seq = newInt64(21)
+		e2.Seq = newInt64(22)
+		err = r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{e1, e2})
+		assert.Equal(t, ErrSequenceAlreadyUpdated, err)
+
+		// not existed event
+		e3 := cacheinv.InvalidateEvent{ID: 3, Seq: newInt64(23)}
+		err = r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{e3})
+		assert.Equal(t, ErrSequenceAlreadyUpdated, err)
+
+		e1.Seq = newInt64(11)
+		events, err := r.repo.GetLastEvents(r.ctx, 16)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []cacheinv.InvalidateEvent{e1}, events)
+	})
+
+	t.Run("set updated at", func(t *testing.T) {
+		r := newRepoTest()
+
+		r.insertData("key01")
+		r.db.MustExec(`UPDATE invalidate_events SET updated_at = NOW() - INTERVAL '1 hour'`)
+
+		err := r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{{ID: 1, Seq: newInt64(11)}})
+		assert.Equal(t, nil, err)
+
+		var updated bool
+		err = r.db.Get(&updated, `SELECT updated_at > NOW() - INTERVAL '1 minute' FROM invalidate_events WHERE id = 1`)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, true, updated)
+	})
+}
+
+var dbErrorOnce sync.Once
+var globalDBError *sqlx.DB
+
+func initDBWithError() *sqlx.DB {
+	dbErrorOnce.Do(func() {
+		var err error
+		globalDBError, err = sqlx.Open(
+			"postgres",
+			"postgres://postgres:1@localhost:5433/cache_inv?sslmode=disable",
+		)
+		if err != nil {
+			panic(err)
+		}
+	})
+	return globalDBError
+}
+
+func newRepoTestWithError() *repoTest {
+	db := initDBWithError()
+
+	return &repoTest{
+		ctx:  context.Background(),
+		db:   db,
+		repo: NewRepository(db, "invalidate_events", "invalidate_offsets"),
+	}
+}
+
+func TestRepo_Repo_WithError(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		r := newRepoTestWithError()
+
+		var err error
+
+		_, err = r.repo.GetLastEvents(r.ctx, 16)
+		assert.Error(t, err)
+
+		_, err = r.repo.GetUnprocessedEvents(r.ctx, 16)
+		assert.Error(t, err)
+
+		_, err = r.repo.GetEventsFrom(r.ctx, 100, 16)
+		assert.Error(t, err)
+
+		err = r.repo.UpdateSequences(r.ctx, []cacheinv.InvalidateEvent{
+			{
+				ID:   1,
+				Seq:  newInt64(11),
+				Data: "Key01",
+			},
+		})
+		assert.Error(t, err)
+
+		_, err = r.repo.GetMinSequence(r.ctx)
+		assert.Error(t, err)
+
+		err = r.repo.DeleteEventsBefore(r.ctx, 100)
+		assert.Error(t, err)
+
+		_, err = r.repo.GetLastSequence(r.ctx, "server01")
+		assert.Error(t, err)
+
+		err = r.repo.SetLastSequence(r.ctx, "server01", 70)
+		assert.Error(t, err)
+	})
+}
@@ -2,25 +2,54 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/redis/go-redis/v9"
 
 	"github.com/QuangTung97/cacheinv"
 )
 
 type clientImpl struct {
+	conf clientConfig
+
 	serverIDs []int64
-	clients   map[int64]*redis.Client
+	clients   map[int64]redis.UniversalClient
 }
 
 var _ cacheinv.Client = &clientImpl{}
+var _ cacheinv.PatternClient = &clientImpl{}
+var _ cacheinv.TagClient = &clientImpl{}
+var _ cacheinv.VersionedClient = &clientImpl{}
+var _ cacheinv.ExpireClient = &clientImpl{}
+var _ cacheinv.FlushClient = &clientImpl{}
 
 // NewClient ...
-func NewClient(clients map[int64]*redis.Client) cacheinv.Client {
+func NewClient(clients map[int64]*redis.Client, options ...Option) cacheinv.Client {
+	universalClients := make(map[int64]redis.UniversalClient, len(clients))
+	for serverID, client := range clients {
+		universalClients[serverID] = client
+	}
+	return NewUniversalClient(universalClients, options...)
+}
+
+// NewUniversalClient creates a client with each cache server being either
+// a standalone redis (*redis.Client) or a redis cluster (*redis.ClusterClient).
+// On a redis cluster, keys are deleted by pipelining the commands of keys grouped by hash slots
+func NewUniversalClient(clients map[int64]redis.UniversalClient, options ...Option) cacheinv.Client {
 	servers := make([]int64, 0, len(clients))
-	for serverID := range clients {
+	for serverID, client := range clients {
+		switch client.(type) {
+		case *redis.Client, *redis.ClusterClient:
+		default:
+			panic(fmt.Sprintf("redis: not supported client type %T of server %d", client, serverID))
+		}
 		servers = append(servers, serverID)
 	}
 	sort.Slice(servers, func(i, j int) bool {
@@ -28,6 +57,8 @@ func NewClient(clients map[int64]*redis.Client) cacheinv.Client {
 	})
 
 	return &clientImpl{
+		conf: newClientConfig(options),
+
 		serverIDs: servers,
 		clients:   clients,
 	}
@@ -40,10 +71,210 @@ func (c *clientImpl) GetServerIDs() []int64 {
 
 // GetServerName ...
 func (c *clientImpl) GetServerName(serverID int64) string {
+	return getServerName(serverID)
+}
+
+func getServerName(serverID int64) string {
 	return fmt.Sprintf("redis:%d", serverID)
 }
 
-// DeleteCacheKeys ...
+var redisDeleteChunkKeys = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "redis_delete_chunk_keys",
+	Help:    "number of keys of the chunks of the deletes, see WithDeleteChunkSize",
+	Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+}, []string{"pipeline", "server_name"})
+
+var redisDeleteChunkDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "redis_delete_chunk_duration_seconds",
+	Help: "duration of the DEL / UNLINK commands of the chunks of the deletes, see WithDeleteChunkSize",
+}, []string{"pipeline", "server_name"})
+
+// DeleteCacheKeys deletes the keys using DEL, or UNLINK if configured by WithUnlink.
+// The keys are split into chunks of WithDeleteChunkSize keys, one command per chunk sent one after another,
+// so that the latency of each chunk can be observed
 func (c *clientImpl) DeleteCacheKeys(ctx context.Context, serverID int64, keys []string) error {
-	return c.clients[serverID].Del(ctx, keys...).Err()
+	client := c.clients[serverID]
+
+	chunks := splitKeys(client, keys, c.conf.deleteChunkSize)
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	fn := delCommand
+	if c.conf.unlink {
+		fn = unlinkCommand
+	}
+
+	serverName := c.GetServerName(serverID)
+	chunkKeys := redisDeleteChunkKeys.WithLabelValues(c.conf.pipelineName, serverName)
+	chunkDurationSeconds := redisDeleteChunkDurationSeconds.WithLabelValues(c.conf.pipelineName, serverName)
+
+	for _, chunk := range chunks {
+		start := time.Now()
+		err := fn(ctx, client, chunk...).Err()
+		if err != nil {
+			return err
+		}
+
+		chunkDurationSeconds.Observe(time.Since(start).Seconds())
+		chunkKeys.Observe(float64(len(chunk)))
+	}
+	return nil
+}
+
+// FlushCache removes all keys of the current database using FLUSHDB, on all master nodes of a redis cluster
+func (c *clientImpl) FlushCache(ctx context.Context, serverID int64) error {
+	return forEachNode(ctx, c.clients[serverID], func(ctx context.Context, node *redis.Client) error {
+		return node.FlushDB(ctx).Err()
+	})
+}
+
+var redisPatternMatchedKeysTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "redis_pattern_matched_keys_total",
+	Help: "number of keys matched and deleted by pattern invalidations",
+}, []string{"pipeline", "server_name"})
+
+// DeleteCachePattern deletes all keys matching *pattern* using SCAN MATCH and UNLINK.
+// The SCAN cursor is stored on the redis server after each batch,
+// so that a restarted invalidation of the same event continues from the last stored cursor.
+// The checkpoint key is '<prefix><eventSeq>:<pattern>', the other events with the same pattern scan from the beginning.
+// On a redis cluster, every master node is scanned with its own cursor
+func (c *clientImpl) DeleteCachePattern(ctx context.Context, serverID int64, eventSeq uint64, pattern string) error {
+	_, isCluster := c.clients[serverID].(*redis.ClusterClient)
+
+	return forEachNode(ctx, c.clients[serverID], func(ctx context.Context, node *redis.Client) error {
+		checkpointKey := c.conf.checkpointKeyPrefix + strconv.FormatUint(eventSeq, 10) + ":" + pattern
+		if isCluster {
+			checkpointKey = c.conf.checkpointKeyPrefix + node.Options().Addr + ":" +
+				strconv.FormatUint(eventSeq, 10) + ":" + pattern
+		}
+		return c.deleteNodePattern(ctx, serverID, node, checkpointKey, pattern)
+	})
+}
+
+// deleteNodePattern scans the keys on the *node*, the keys and the checkpoint are updated using the server client
+func (c *clientImpl) deleteNodePattern(
+	ctx context.Context, serverID int64, node *redis.Client,
+	checkpointKey string, pattern string,
+) error {
+	client := c.clients[serverID]
+	serverName := c.GetServerName(serverID)
+
+	cursor, err := client.Get(ctx, checkpointKey).Uint64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+
+	for {
+		keys, nextCursor, err := node.Scan(ctx, cursor, pattern, c.conf.scanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		keys = c.removeCheckpointKeys(keys)
+		if len(keys) > 0 {
+			err := runMultiKeyCommand(ctx, client, splitKeys(client, keys, c.conf.deleteChunkSize), unlinkCommand)
+			if err != nil {
+				return err
+			}
+			redisPatternMatchedKeysTotal.WithLabelValues(c.conf.pipelineName, serverName).Add(float64(len(keys)))
+		}
+
+		if nextCursor == 0 {
+			return client.Del(ctx, checkpointKey).Err()
+		}
+		cursor = nextCursor
+
+		err = client.Set(ctx, checkpointKey, cursor, c.conf.checkpointTTL).Err()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *clientImpl) removeCheckpointKeys(keys []string) []string {
+	result := keys[:0]
+	for _, key := range keys {
+		if strings.HasPrefix(key, c.conf.checkpointKeyPrefix) {
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+var redisTagDeletedKeysTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "redis_tag_deleted_keys_total",
+	Help: "number of keys deleted by tag invalidations",
+}, []string{"pipeline", "server_name"})
+
+// DeleteCacheTags deletes the members of the tag sets using SSCAN and UNLINK, then deletes the tag sets
+func (c *clientImpl) DeleteCacheTags(ctx context.Context, serverID int64, tags []string) error {
+	for _, tag := range tags {
+		err := c.deleteCacheTag(ctx, serverID, tag)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *clientImpl) deleteCacheTag(ctx context.Context, serverID int64, tag string) error {
+	client := c.clients[serverID]
+	serverName := c.GetServerName(serverID)
+
+	var cursor uint64
+	for {
+		keys, nextCursor, err := client.SScan(ctx, tag, cursor, "", c.conf.scanCount).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			err := runMultiKeyCommand(ctx, client, splitKeys(client, keys, c.conf.deleteChunkSize), unlinkCommand)
+			if err != nil {
+				return err
+			}
+			redisTagDeletedKeysTotal.WithLabelValues(c.conf.pipelineName, serverName).Add(float64(len(keys)))
+		}
+
+		if nextCursor == 0 {
+			return client.Del(ctx, tag).Err()
+		}
+		cursor = nextCursor
+	}
+}
+
+var versionedDeleteScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[2]))
+if current and current >= tonumber(ARGV[1]) then
+	return 0
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// DeleteVersionedKeys deletes the keys only if the versions stored in the version keys
+// (see WithVersionKeySuffix) are lower than *version*.
+// In a redis cluster, the cache keys should contain hash tags, so that the version keys are in the same slots
+func (c *clientImpl) DeleteVersionedKeys(ctx context.Context, serverID int64, keys []string, version uint64) error {
+	client := c.clients[serverID]
+
+	pipe := client.Pipeline()
+	for _, key := range keys {
+		versionedDeleteScript.Eval(ctx, pipe, []string{key, key + c.conf.versionKeySuffix}, version)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ExpireCacheKeys sets the TTL of the keys to *ttl* using PEXPIRE, not existed keys are ignored
+func (c *clientImpl) ExpireCacheKeys(ctx context.Context, serverID int64, keys []string, ttl time.Duration) error {
+	client := c.clients[serverID]
+
+	pipe := client.Pipeline()
+	for _, key := range keys {
+		pipe.PExpire(ctx, key, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
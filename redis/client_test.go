@@ -2,8 +2,10 @@ package redis
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -33,7 +35,7 @@ func initClients() map[int64]*redis.Client {
 	return globalClients
 }
 
-func newClientTest(_ *testing.T) *clientTest {
+func newClientTest(_ *testing.T, options ...Option) *clientTest {
 	clients := initClients()
 	for _, c := range clients {
 		err := c.FlushAll(context.Background()).Err()
@@ -44,7 +46,7 @@ func newClientTest(_ *testing.T) *clientTest {
 
 	return &clientTest{
 		redisClients: clients,
-		client:       NewClient(clients),
+		client:       NewClient(clients, options...),
 	}
 }
 
@@ -129,3 +131,364 @@ func TestClient(t *testing.T) {
 		assert.Equal(t, "data02", val)
 	})
 }
+
+// commandRecorder is a redis hook recording the names and the keys of the commands
+type commandRecorder struct {
+	mut       sync.Mutex
+	commands  [][]string
+	pipelines int
+}
+
+func (r *commandRecorder) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (r *commandRecorder) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		r.record(cmd)
+		return next(ctx, cmd)
+	}
+}
+
+func (r *commandRecorder) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		r.mut.Lock()
+		r.pipelines++
+		r.mut.Unlock()
+
+		for _, cmd := range cmds {
+			r.record(cmd)
+		}
+		return next(ctx, cmds)
+	}
+}
+
+func (r *commandRecorder) record(cmd redis.Cmder) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	command := make([]string, 0, len(cmd.Args()))
+	for _, arg := range cmd.Args() {
+		command = append(command, fmt.Sprint(arg))
+	}
+	r.commands = append(r.commands, command)
+}
+
+func TestClient_DeleteChunks(t *testing.T) {
+	ctx := context.Background()
+
+	newChunkTest := func(t *testing.T, options ...Option) (*redis.Client, *commandRecorder, cacheinv.Client) {
+		newClientTest(t)
+
+		client := redis.NewClient(&redis.Options{
+			Addr: "localhost:6379",
+		})
+		t.Cleanup(func() { _ = client.Close() })
+
+		recorder := &commandRecorder{}
+		client.AddHook(recorder)
+
+		return client, recorder, NewClient(map[int64]*redis.Client{11: client}, options...)
+	}
+
+	t.Run("unlink in chunks", func(t *testing.T) {
+		client, recorder, c := newChunkTest(t, WithUnlink(), WithDeleteChunkSize(2))
+
+		err := client.MSet(ctx, "key01", "data01", "key02", "data02", "key03", "data03", "key04", "data04").Err()
+		assert.Equal(t, nil, err)
+
+		recorder.commands = nil
+
+		err = c.DeleteCacheKeys(ctx, 11, []string{"key01", "key02", "key03"})
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, [][]string{
+			{"unlink", "key01", "key02"},
+			{"unlink", "key03"},
+		}, recorder.commands)
+		// each chunk is timed separately
+		assert.Equal(t, 0, recorder.pipelines)
+
+		keys, err := client.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"key04"}, keys)
+	})
+
+	t.Run("default single del", func(t *testing.T) {
+		_, recorder, c := newChunkTest(t)
+
+		err := c.DeleteCacheKeys(ctx, 11, []string{"key01", "key02", "key03"})
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, [][]string{
+			{"del", "key01", "key02", "key03"},
+		}, recorder.commands)
+	})
+
+	t.Run("empty keys", func(t *testing.T) {
+		_, recorder, c := newChunkTest(t)
+
+		err := c.DeleteCacheKeys(ctx, 11, nil)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 0, len(recorder.commands))
+	})
+}
+
+func TestClient_DeletePattern(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		c := newClientTest(t, WithScanCount(3))
+
+		client1 := c.redisClients[11]
+
+		for i := 0; i < 10; i++ {
+			err := client1.Set(ctx, fmt.Sprintf("user:42:%02d", i), []byte("data"), 0).Err()
+			assert.Equal(t, nil, err)
+		}
+		err := client1.Set(ctx, "user:43:01", []byte("data"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		patternClient, ok := c.client.(cacheinv.PatternClient)
+		assert.Equal(t, true, ok)
+
+		err = patternClient.DeleteCachePattern(ctx, 11, 1, "user:42:*")
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"user:43:01"}, keys)
+	})
+
+	t.Run("continue from checkpoint", func(t *testing.T) {
+		c := newClientTest(t, WithScanCheckpoint("checkpoint:", time.Minute))
+
+		client1 := c.redisClients[11]
+
+		err := client1.Set(ctx, "user:42:01", []byte("data"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.Set(ctx, "checkpoint:5:*", "0", 0).Err()
+		assert.Equal(t, nil, err)
+
+		patternClient, ok := c.client.(cacheinv.PatternClient)
+		assert.Equal(t, true, ok)
+
+		err = patternClient.DeleteCachePattern(ctx, 11, 5, "*")
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{}, keys)
+	})
+
+	t.Run("not continue from checkpoint of other event", func(t *testing.T) {
+		c := newClientTest(t, WithScanCheckpoint("checkpoint:", time.Minute))
+
+		client1 := c.redisClients[11]
+
+		for i := 0; i < 5; i++ {
+			err := client1.Set(ctx, fmt.Sprintf("user:42:%02d", i), []byte("data"), 0).Err()
+			assert.Equal(t, nil, err)
+		}
+
+		// left by a failed invalidation of the event with seq = 4
+		err := client1.Set(ctx, "checkpoint:4:user:*", "100", 0).Err()
+		assert.Equal(t, nil, err)
+
+		patternClient, ok := c.client.(cacheinv.PatternClient)
+		assert.Equal(t, true, ok)
+
+		err = patternClient.DeleteCachePattern(ctx, 11, 5, "user:*")
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"checkpoint:4:user:*"}, keys)
+	})
+
+	t.Run("not delete other servers", func(t *testing.T) {
+		c := newClientTest(t)
+
+		client2 := c.redisClients[12]
+
+		err := client2.Set(ctx, "user:42:01", []byte("data"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		patternClient, ok := c.client.(cacheinv.PatternClient)
+		assert.Equal(t, true, ok)
+
+		err = patternClient.DeleteCachePattern(ctx, 11, 1, "user:*")
+		assert.Equal(t, nil, err)
+
+		val, err := client2.Get(ctx, "user:42:01").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "data", val)
+	})
+}
+
+func TestClient_DeleteTags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		c := newClientTest(t, WithScanCount(2))
+
+		client1 := c.redisClients[11]
+
+		for i := 0; i < 5; i++ {
+			key := fmt.Sprintf("product:99:%02d", i)
+			err := client1.Set(ctx, key, []byte("data"), 0).Err()
+			assert.Equal(t, nil, err)
+
+			err = client1.SAdd(ctx, "tag:product:99", key).Err()
+			assert.Equal(t, nil, err)
+		}
+
+		err := client1.Set(ctx, "product:98:01", []byte("data"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		err = client1.SAdd(ctx, "tag:product:98", "product:98:01").Err()
+		assert.Equal(t, nil, err)
+
+		tagClient, ok := c.client.(cacheinv.TagClient)
+		assert.Equal(t, true, ok)
+
+		err = tagClient.DeleteCacheTags(ctx, 11, []string{"tag:product:99", "tag:product:100"})
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.ElementsMatch(t, []string{"product:98:01", "tag:product:98"}, keys)
+	})
+
+	t.Run("tag with wrong type", func(t *testing.T) {
+		c := newClientTest(t)
+
+		client1 := c.redisClients[11]
+
+		err := client1.Set(ctx, "tag:product:99", []byte("data"), 0).Err()
+		assert.Equal(t, nil, err)
+
+		tagClient, ok := c.client.(cacheinv.TagClient)
+		assert.Equal(t, true, ok)
+
+		err = tagClient.DeleteCacheTags(ctx, 11, []string{"tag:product:99"})
+		assert.Error(t, err)
+	})
+}
+
+func TestClient_DeleteVersionedKeys(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		c := newClientTest(t)
+
+		client1 := c.redisClients[11]
+
+		err := client1.MSet(ctx,
+			"key01", "data01", "key01:version", "10",
+			"key02", "data02", "key02:version", "11",
+			"key03", "data03", "key03:version", "12",
+			"key04", "data04",
+			"key05", "data05", "key05:version", "invalid",
+		).Err()
+		assert.Equal(t, nil, err)
+
+		versionedClient, ok := c.client.(cacheinv.VersionedClient)
+		assert.Equal(t, true, ok)
+
+		err = versionedClient.DeleteVersionedKeys(ctx, 11, []string{
+			"key01", "key02", "key03", "key04", "key05", "key06",
+		}, 11)
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "key0?").Result()
+		assert.Equal(t, nil, err)
+		assert.ElementsMatch(t, []string{"key02", "key03"}, keys)
+
+		keys, err = client1.Keys(ctx, "*:version").Result()
+		assert.Equal(t, nil, err)
+		assert.ElementsMatch(t, []string{"key01:version", "key02:version", "key03:version", "key05:version"}, keys)
+	})
+
+	t.Run("with version key suffix", func(t *testing.T) {
+		c := newClientTest(t, WithVersionKeySuffix("#ver"))
+
+		client1 := c.redisClients[11]
+
+		err := client1.MSet(ctx,
+			"key01", "data01", "key01#ver", "10",
+			"key02", "data02", "key02#ver", "20",
+		).Err()
+		assert.Equal(t, nil, err)
+
+		versionedClient, ok := c.client.(cacheinv.VersionedClient)
+		assert.Equal(t, true, ok)
+
+		err = versionedClient.DeleteVersionedKeys(ctx, 11, []string{"key01", "key02"}, 15)
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "key0?").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"key02"}, keys)
+	})
+}
+
+func TestClient_ExpireKeys(t *testing.T) {
+	ctx := context.Background()
+
+	c := newClientTest(t)
+
+	client1 := c.redisClients[11]
+
+	err := client1.MSet(ctx, "key01", "data01", "key02", "data02", "key03", "data03").Err()
+	assert.Equal(t, nil, err)
+
+	expireClient, ok := c.client.(cacheinv.ExpireClient)
+	assert.Equal(t, true, ok)
+
+	err = expireClient.ExpireCacheKeys(ctx, 11, []string{"key01", "key02", "key04"}, 5*time.Second)
+	assert.Equal(t, nil, err)
+
+	keys, err := client1.Keys(ctx, "key0?").Result()
+	assert.Equal(t, nil, err)
+	assert.ElementsMatch(t, []string{"key01", "key02", "key03"}, keys)
+
+	ttl, err := client1.PTTL(ctx, "key01").Result()
+	assert.Equal(t, nil, err)
+	assert.Greater(t, ttl, 4*time.Second)
+	assert.LessOrEqual(t, ttl, 5*time.Second)
+
+	ttl, err = client1.PTTL(ctx, "key03").Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+}
+
+func TestClient_FlushCache(t *testing.T) {
+	ctx := context.Background()
+
+	c := newClientTest(t)
+
+	client1 := c.redisClients[11]
+	client2 := c.redisClients[12]
+
+	err := client1.MSet(ctx, "key01", "data01", "key02", "data02").Err()
+	assert.Equal(t, nil, err)
+	err = client2.Set(ctx, "key03", "data03", 0).Err()
+	assert.Equal(t, nil, err)
+
+	flushClient, ok := c.client.(cacheinv.FlushClient)
+	assert.Equal(t, true, ok)
+
+	err = flushClient.FlushCache(ctx, 11)
+	assert.Equal(t, nil, err)
+
+	keys, err := client1.Keys(ctx, "*").Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(keys))
+
+	// other servers are not flushed
+	keys, err = client2.Keys(ctx, "*").Result()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"key03"}, keys)
+}
@@ -0,0 +1,122 @@
+package redis
+
+import (
+	"context"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const clusterSlotsTotal = 16384
+
+// hashSlot returns the redis cluster hash slot of the key.
+// If the key contains a non-empty hash tag (e.g. 'user:{42}:name'), only the hash tag is hashed
+func hashSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % clusterSlotsTotal
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) used by redis cluster
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for k := 0; k < 8; k++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// groupKeysBySlot splits the keys into groups of the same hash slots, in the order of the first keys of the groups
+func groupKeysBySlot(keys []string) [][]string {
+	slotIndex := map[int]int{}
+
+	var result [][]string
+	for _, key := range keys {
+		slot := hashSlot(key)
+
+		index, existed := slotIndex[slot]
+		if !existed {
+			index = len(result)
+			slotIndex[slot] = index
+			result = append(result, nil)
+		}
+		result[index] = append(result[index], key)
+	}
+	return result
+}
+
+type multiKeyCommand func(ctx context.Context, cmd redis.Cmdable, keys ...string) *redis.IntCmd
+
+func delCommand(ctx context.Context, cmd redis.Cmdable, keys ...string) *redis.IntCmd {
+	return cmd.Del(ctx, keys...)
+}
+
+func unlinkCommand(ctx context.Context, cmd redis.Cmdable, keys ...string) *redis.IntCmd {
+	return cmd.Unlink(ctx, keys...)
+}
+
+// splitKeys splits the keys into chunks of at most *chunkSize* keys, no limit if chunkSize <= 0.
+// On a redis cluster, the keys are grouped by hash slots first to avoid CROSSSLOT errors
+func splitKeys(client redis.UniversalClient, keys []string, chunkSize int) [][]string {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	groups := [][]string{keys}
+	if _, ok := client.(*redis.ClusterClient); ok {
+		groups = groupKeysBySlot(keys)
+	}
+	if chunkSize <= 0 {
+		return groups
+	}
+
+	var result [][]string
+	for _, group := range groups {
+		for len(group) > chunkSize {
+			result = append(result, group[:chunkSize])
+			group = group[chunkSize:]
+		}
+		result = append(result, group)
+	}
+	return result
+}
+
+// runMultiKeyCommand runs a multi-key command (DEL, UNLINK) for each chunk of keys (see splitKeys).
+// Multiple chunks are sent over a pipeline, on a redis cluster the commands are pipelined
+// to the nodes owning the slots, following MOVED / ASK redirects
+func runMultiKeyCommand(
+	ctx context.Context, client redis.UniversalClient,
+	chunks [][]string, fn multiKeyCommand,
+) error {
+	if len(chunks) == 1 {
+		return fn(ctx, client, chunks[0]...).Err()
+	}
+
+	pipe := client.Pipeline()
+	for _, chunk := range chunks {
+		fn(ctx, pipe, chunk...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// forEachNode calls *fn* on the standalone client, or on every master node of the redis cluster
+func forEachNode(
+	ctx context.Context, client redis.UniversalClient,
+	fn func(ctx context.Context, node *redis.Client) error,
+) error {
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, fn)
+	}
+	return fn(ctx, client.(*redis.Client))
+}
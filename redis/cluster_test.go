@@ -0,0 +1,198 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+func TestHashSlot(t *testing.T) {
+	assert.Equal(t, 0x31C3, int(crc16("123456789")))
+
+	assert.Equal(t, 12182, hashSlot("foo"))
+	assert.Equal(t, 5061, hashSlot("bar"))
+
+	// with hash tags
+	assert.Equal(t, hashSlot("user1000"), hashSlot("{user1000}.following"))
+	assert.Equal(t, hashSlot("user1000"), hashSlot("{user1000}.followers"))
+	assert.NotEqual(t, hashSlot("bar"), hashSlot("foo{}{bar}"))
+	assert.NotEqual(t, hashSlot("bar"), hashSlot("{bar"))
+	assert.Equal(t, hashSlot("bar"), hashSlot("foo{bar}{zap}"))
+}
+
+func TestGroupKeysBySlot(t *testing.T) {
+	assert.Equal(t, [][]string(nil), groupKeysBySlot(nil))
+
+	assert.Equal(t, [][]string{
+		{"foo", "{foo}:version"},
+		{"bar", "x{bar}"},
+	}, groupKeysBySlot([]string{"foo", "bar", "{foo}:version", "x{bar}"}))
+}
+
+// newClusterClientTest builds a redis cluster client of server 31 with slots split between the two test redis:
+// slots [0, 8192) on the redis of server 11 and slots [8192, 16384) on the redis of server 12
+func newClusterClientTest(t *testing.T, options ...Option) *clientTest {
+	c := newClientTest(t)
+
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func(ctx context.Context) ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{
+				{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: "localhost:6379"}}},
+				{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: "localhost:6380"}}},
+			}, nil
+		},
+	})
+	t.Cleanup(func() { _ = cluster.Close() })
+
+	c.client = NewUniversalClient(map[int64]redis.UniversalClient{
+		11: c.redisClients[11],
+		31: cluster,
+	}, options...)
+	return c
+}
+
+func TestClient_Cluster(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("normal", func(t *testing.T) {
+		c := newClusterClientTest(t)
+		assert.Equal(t, []int64{11, 31}, c.client.GetServerIDs())
+		assert.Equal(t, "redis:31", c.client.GetServerName(31))
+	})
+
+	t.Run("delete keys on multiple nodes", func(t *testing.T) {
+		c := newClusterClientTest(t)
+
+		client1 := c.redisClients[11]
+		client2 := c.redisClients[12]
+
+		err := client1.MSet(ctx, "bar", "data01", "x{bar}", "data02", "key01", "data03").Err()
+		assert.Equal(t, nil, err)
+		err = client2.MSet(ctx, "foo", "data04", "{foo}:version", "data05").Err()
+		assert.Equal(t, nil, err)
+
+		err = c.client.DeleteCacheKeys(ctx, 31, []string{"foo", "bar", "{foo}:version", "x{bar}"})
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"key01"}, keys)
+
+		keys, err = client2.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{}, keys)
+	})
+
+	t.Run("delete pattern on all nodes", func(t *testing.T) {
+		c := newClusterClientTest(t, WithScanCount(1))
+
+		client1 := c.redisClients[11]
+		client2 := c.redisClients[12]
+
+		err := client1.MSet(ctx, "user:{bar}:01", "data01", "user:{bar}:02", "data02", "other:{bar}", "data03").Err()
+		assert.Equal(t, nil, err)
+		err = client2.MSet(ctx, "user:{foo}:01", "data04", "other:{foo}", "data05").Err()
+		assert.Equal(t, nil, err)
+
+		patternClient, ok := c.client.(cacheinv.PatternClient)
+		assert.Equal(t, true, ok)
+
+		err = patternClient.DeleteCachePattern(ctx, 31, 1, "user:*")
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"other:{bar}"}, keys)
+
+		keys, err = client2.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"other:{foo}"}, keys)
+	})
+
+	t.Run("delete tags", func(t *testing.T) {
+		c := newClusterClientTest(t)
+
+		client1 := c.redisClients[11]
+		client2 := c.redisClients[12]
+
+		err := client1.MSet(ctx, "bar", "data01", "key01", "data02").Err()
+		assert.Equal(t, nil, err)
+		err = client2.SAdd(ctx, "foo", "bar", "{foo}:01").Err()
+		assert.Equal(t, nil, err)
+		err = client2.Set(ctx, "{foo}:01", "data03", 0).Err()
+		assert.Equal(t, nil, err)
+
+		tagClient, ok := c.client.(cacheinv.TagClient)
+		assert.Equal(t, true, ok)
+
+		err = tagClient.DeleteCacheTags(ctx, 31, []string{"foo"})
+		assert.Equal(t, nil, err)
+
+		keys, err := client1.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"key01"}, keys)
+
+		keys, err = client2.Keys(ctx, "*").Result()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{}, keys)
+	})
+
+	t.Run("flush all nodes", func(t *testing.T) {
+		c := newClusterClientTest(t)
+
+		err := c.redisClients[11].Set(ctx, "bar", "data01", 0).Err()
+		assert.Equal(t, nil, err)
+		err = c.redisClients[12].Set(ctx, "foo", "data02", 0).Err()
+		assert.Equal(t, nil, err)
+
+		flushClient, ok := c.client.(cacheinv.FlushClient)
+		assert.Equal(t, true, ok)
+
+		err = flushClient.FlushCache(ctx, 31)
+		assert.Equal(t, nil, err)
+
+		for _, id := range []int64{11, 12} {
+			keys, err := c.redisClients[id].Keys(ctx, "*").Result()
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []string{}, keys)
+		}
+	})
+}
+
+func TestNewUniversalClient_NotSupported(t *testing.T) {
+	assert.PanicsWithValue(t, "redis: not supported client type *redis.Ring of server 11", func() {
+		NewUniversalClient(map[int64]redis.UniversalClient{
+			11: redis.NewRing(&redis.RingOptions{}),
+		})
+	})
+}
+
+func TestSplitKeys(t *testing.T) {
+	standalone := redis.NewClient(&redis.Options{})
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{})
+
+	assert.Equal(t, [][]string(nil), splitKeys(standalone, nil, 2))
+
+	keys := []string{"foo", "bar", "{foo}:01", "{foo}:02", "x{bar}"}
+
+	assert.Equal(t, [][]string{keys}, splitKeys(standalone, keys, 0))
+	assert.Equal(t, [][]string{
+		{"foo", "bar"},
+		{"{foo}:01", "{foo}:02"},
+		{"x{bar}"},
+	}, splitKeys(standalone, keys, 2))
+
+	assert.Equal(t, [][]string{
+		{"foo", "{foo}:01", "{foo}:02"},
+		{"bar", "x{bar}"},
+	}, splitKeys(cluster, keys, 0))
+	assert.Equal(t, [][]string{
+		{"foo", "{foo}:01"},
+		{"{foo}:02"},
+		{"bar", "x{bar}"},
+	}, splitKeys(cluster, keys, 2))
+}
@@ -0,0 +1,84 @@
+package redis
+
+import (
+	"time"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+type clientConfig struct {
+	scanCount           int64
+	checkpointKeyPrefix string
+	checkpointTTL       time.Duration
+	versionKeySuffix    string
+	pipelineName        string
+
+	unlink          bool
+	deleteChunkSize int
+}
+
+func newClientConfig(options []Option) clientConfig {
+	conf := clientConfig{
+		scanCount:           1000,
+		checkpointKeyPrefix: "cacheinv:scan_cursor:",
+		checkpointTTL:       1 * time.Hour,
+		versionKeySuffix:    ":version",
+		pipelineName:        cacheinv.DefaultPipelineName,
+	}
+
+	for _, fn := range options {
+		fn(&conf)
+	}
+
+	return conf
+}
+
+// Option ...
+type Option func(conf *clientConfig)
+
+// WithScanCount configures the COUNT argument of the SCAN commands used for pattern invalidation
+func WithScanCount(count int64) Option {
+	return func(conf *clientConfig) {
+		conf.scanCount = count
+	}
+}
+
+// WithScanCheckpoint configures the key prefix and the TTL of the keys
+// storing the SCAN cursors of the running pattern invalidations
+func WithScanCheckpoint(keyPrefix string, ttl time.Duration) Option {
+	return func(conf *clientConfig) {
+		conf.checkpointKeyPrefix = keyPrefix
+		conf.checkpointTTL = ttl
+	}
+}
+
+// WithVersionKeySuffix configures the suffix of the keys storing the versions of the cached values.
+// For a cache key 'key01', its version is stored in the key 'key01' + suffix
+func WithVersionKeySuffix(suffix string) Option {
+	return func(conf *clientConfig) {
+		conf.versionKeySuffix = suffix
+	}
+}
+
+// WithPipelineName configures the value of the label 'pipeline' of the metrics of the client,
+// default is cacheinv.DefaultPipelineName
+func WithPipelineName(name string) Option {
+	return func(conf *clientConfig) {
+		conf.pipelineName = name
+	}
+}
+
+// WithUnlink deletes the keys using UNLINK instead of DEL, the values are freed in background by the redis server
+func WithUnlink() Option {
+	return func(conf *clientConfig) {
+		conf.unlink = true
+	}
+}
+
+// WithDeleteChunkSize splits the keys of a delete into chunks of at most *size* keys,
+// one DEL / UNLINK command per chunk, sent one after another. Default is 0, all keys in a single command
+func WithDeleteChunkSize(size int) Option {
+	return func(conf *clientConfig) {
+		conf.deleteChunkSize = size
+	}
+}
@@ -0,0 +1,112 @@
+package redis
+
+import (
+	"context"
+	"log"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+const switchMasterChannel = "+switch-master"
+
+var redisSentinelFailoversTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "redis_sentinel_failovers_total",
+	Help: "number of master switches of the sentinel-managed redis servers",
+}, []string{"pipeline", "server_name"})
+
+// FailoverWatcher logs and counts the master switches of a sentinel-managed redis server.
+// The invalidations are sent to the new master by the failover client (redis.NewFailoverClient),
+// the watcher only surfaces the failovers
+type FailoverWatcher struct {
+	conf clientConfig
+
+	serverName string
+	masterName string
+	sentinels  []*redis.SentinelClient
+
+	mut           sync.Mutex
+	currentMaster string
+}
+
+// NewFailoverWatcher creates a watcher of the master *masterName* of the redis server *serverID*,
+// subscribing to all the *sentinels*, the same failover published by multiple sentinels is counted once
+func NewFailoverWatcher(
+	serverID int64, masterName string, sentinels []*redis.SentinelClient,
+	options ...Option,
+) *FailoverWatcher {
+	return &FailoverWatcher{
+		conf: newClientConfig(options),
+
+		serverName: getServerName(serverID),
+		masterName: masterName,
+		sentinels:  sentinels,
+	}
+}
+
+// Run subscribes to the '+switch-master' events of the sentinels until ctx is cancelled
+func (w *FailoverWatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(len(w.sentinels))
+
+	for _, sentinel := range w.sentinels {
+		go func(sentinel *redis.SentinelClient) {
+			defer wg.Done()
+			w.watchSentinel(ctx, sentinel)
+		}(sentinel)
+	}
+
+	wg.Wait()
+}
+
+func (w *FailoverWatcher) watchSentinel(ctx context.Context, sentinel *redis.SentinelClient) {
+	pubsub := sentinel.Subscribe(ctx, switchMasterChannel)
+	defer func() { _ = pubsub.Close() }()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			w.handleSwitchMaster(msg.Payload)
+		}
+	}
+}
+
+// handleSwitchMaster handles the payload: <master name> <old ip> <old port> <new ip> <new port>
+func (w *FailoverWatcher) handleSwitchMaster(payload string) {
+	fields := strings.Fields(payload)
+	if len(fields) != 5 {
+		log.Printf("[ERROR] invalid sentinel %s message: '%s'\n", switchMasterChannel, payload)
+		return
+	}
+	if fields[0] != w.masterName {
+		return
+	}
+
+	oldMaster := net.JoinHostPort(fields[1], fields[2])
+	newMaster := net.JoinHostPort(fields[3], fields[4])
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if newMaster == w.currentMaster {
+		return
+	}
+	w.currentMaster = newMaster
+
+	log.Printf(
+		"[WARN] redis server '%s' failover, master '%s' switched from '%s' to '%s'\n",
+		w.serverName, w.masterName, oldMaster, newMaster,
+	)
+	redisSentinelFailoversTotal.WithLabelValues(w.conf.pipelineName, w.serverName).Inc()
+}
@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailoverWatcher_HandleSwitchMaster(t *testing.T) {
+	w := NewFailoverWatcher(41, "master01", nil, WithPipelineName("sentinel_test"))
+	metric := redisSentinelFailoversTotal.WithLabelValues("sentinel_test", "redis:41")
+
+	w.handleSwitchMaster("master01 10.0.0.1 6379 10.0.0.2 6379")
+	assert.Equal(t, "10.0.0.2:6379", w.currentMaster)
+	assert.Equal(t, float64(1), testutil.ToFloat64(metric))
+
+	// published by another sentinel
+	w.handleSwitchMaster("master01 10.0.0.1 6379 10.0.0.2 6379")
+	assert.Equal(t, float64(1), testutil.ToFloat64(metric))
+
+	// other masters
+	w.handleSwitchMaster("master02 10.0.0.2 6379 10.0.0.1 6379")
+	assert.Equal(t, "10.0.0.2:6379", w.currentMaster)
+
+	// invalid message
+	w.handleSwitchMaster("master01 10.0.0.2")
+	assert.Equal(t, "10.0.0.2:6379", w.currentMaster)
+
+	w.handleSwitchMaster("master01 10.0.0.2 6379 10.0.0.1 6379")
+	assert.Equal(t, "10.0.0.1:6379", w.currentMaster)
+	assert.Equal(t, float64(2), testutil.ToFloat64(metric))
+}
+
+func TestFailoverWatcher_Run(t *testing.T) {
+	clients := initClients()
+
+	sentinel := redis.NewSentinelClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	defer func() { _ = sentinel.Close() }()
+
+	w := NewFailoverWatcher(42, "master01", []*redis.SentinelClient{sentinel},
+		WithPipelineName("sentinel_test"),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		w.Run(ctx)
+	}()
+
+	metric := redisSentinelFailoversTotal.WithLabelValues("sentinel_test", "redis:42")
+	assert.Eventually(t, func() bool {
+		err := clients[11].Publish(ctx, switchMasterChannel, "master01 10.0.0.1 6379 10.0.0.2 6379").Err()
+		assert.Equal(t, nil, err)
+		return testutil.ToFloat64(metric) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	w.mut.Lock()
+	assert.Equal(t, "10.0.0.2:6379", w.currentMaster)
+	w.mut.Unlock()
+}
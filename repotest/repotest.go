@@ -0,0 +1,458 @@
+// Package repotest provides the conformance test suite for implementations of cacheinv.Repository
+package repotest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/QuangTung97/eventx/helpers"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/QuangTung97/cacheinv"
+)
+
+// Fixture is the Repository under test with the function for inserting events
+type Fixture struct {
+	Repo cacheinv.Repository
+
+	// InsertEvents inserts events with null sequence numbers,
+	// in the same order of *dataList*, with ids starting from 1
+	InsertEvents func(dataList ...string)
+
+	// InsertEventsWithAge is the same as InsertEvents, but the events are created *age* ago,
+	// optional, for testing the implementations of cacheinv.AgeRetentionRepository
+	InsertEventsWithAge func(age time.Duration, dataList ...string)
+}
+
+// Factory creates a Fixture with empty events and offsets for each test case
+type Factory func(t *testing.T) Fixture
+
+// Run runs the conformance test suite against the Repository created by *factory*
+func Run(t *testing.T, factory Factory) {
+	t.Run("get events", func(t *testing.T) {
+		testGetEvents(t, factory(t))
+	})
+	t.Run("update sequences out of order", func(t *testing.T) {
+		testUpdateSequencesOutOfOrder(t, factory(t))
+	})
+	t.Run("update sequences partial", func(t *testing.T) {
+		testUpdateSequencesPartial(t, factory(t))
+	})
+	t.Run("update sequences already updated", func(t *testing.T) {
+		testUpdateSequencesAlreadyUpdated(t, factory(t))
+	})
+	t.Run("update sequences empty", func(t *testing.T) {
+		f := factory(t)
+		err := f.Repo.UpdateSequences(context.Background(), nil)
+		assert.Equal(t, nil, err)
+	})
+	t.Run("delete events", func(t *testing.T) {
+		testDeleteEvents(t, factory(t))
+	})
+	t.Run("offsets", func(t *testing.T) {
+		testOffsets(t, factory(t))
+	})
+	t.Run("latest sequence older than", func(t *testing.T) {
+		testLatestSequenceOlderThan(t, factory(t))
+	})
+	t.Run("from lib", func(t *testing.T) {
+		testFromLib(t, factory)
+	})
+}
+
+// resettableRepo is the Repository of the current Fixture, replaced by a new one on each truncation
+type resettableRepo struct {
+	cacheinv.Repository
+}
+
+// testFromLib runs the test suite of eventx for implementations of eventx.Repository
+func testFromLib(t *testing.T, factory Factory) {
+	var f Fixture
+	repo := &resettableRepo{}
+
+	helpers.CheckRepoImpl[cacheinv.InvalidateEvent](
+		t,
+		repo,
+		func(e *cacheinv.InvalidateEvent, id int64) {
+			e.ID = id
+		},
+		func(e *cacheinv.InvalidateEvent, seq uint64) {
+			e.Seq = newInt64(int64(seq))
+		},
+		func() cacheinv.InvalidateEvent {
+			index := rand.Intn(100_000)
+			return cacheinv.InvalidateEvent{
+				Data: fmt.Sprintf("KEY:%d", index),
+			}
+		},
+		func(events []cacheinv.InvalidateEvent) {
+			dataList := make([]string, 0, len(events))
+			for _, e := range events {
+				dataList = append(dataList, e.Data)
+			}
+			f.InsertEvents(dataList...)
+		},
+		func() {
+			f = factory(t)
+			repo.Repository = f.Repo
+		},
+	)
+}
+
+func newEvent(id int64, data string) cacheinv.InvalidateEvent {
+	return cacheinv.InvalidateEvent{
+		ID:   id,
+		Data: data,
+	}
+}
+
+func newInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{
+		Valid: true,
+		Int64: v,
+	}
+}
+
+func testGetEvents(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	events, err := repo.GetLastEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(events))
+
+	events, err = repo.GetUnprocessedEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(events))
+
+	f.InsertEvents("key01,key02", "key03,key04", "key05")
+
+	e1 := newEvent(1, "key01,key02")
+	e2 := newEvent(2, "key03,key04")
+	e3 := newEvent(3, "key05")
+
+	// events with null sequence numbers are ignored
+	events, err = repo.GetLastEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(events))
+
+	events, err = repo.GetUnprocessedEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e1, e2, e3}, events)
+
+	// get with limit
+	events, err = repo.GetUnprocessedEvents(ctx, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e1, e2}, events)
+
+	minSeq, err := repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, minSeq)
+
+	// update sequences
+	e1.Seq = newInt64(11)
+	e2.Seq = newInt64(12)
+
+	err = repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{e1, e2})
+	assert.Equal(t, nil, err)
+
+	events, err = repo.GetLastEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e1, e2}, events)
+
+	events, err = repo.GetUnprocessedEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e3}, events)
+
+	// update sequence of the last event
+	e3.Seq = newInt64(13)
+	err = repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{e3})
+	assert.Equal(t, nil, err)
+
+	// get last events with limit returns the events with the highest sequence numbers
+	events, err = repo.GetLastEvents(ctx, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e2, e3}, events)
+
+	events, err = repo.GetEventsFrom(ctx, 11, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e1, e2, e3}, events)
+
+	events, err = repo.GetEventsFrom(ctx, 11, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e1, e2}, events)
+
+	events, err = repo.GetEventsFrom(ctx, 12, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{e2, e3}, events)
+
+	events, err = repo.GetEventsFrom(ctx, 14, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(events))
+
+	minSeq, err = repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(11), minSeq)
+}
+
+func testUpdateSequencesOutOfOrder(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	f.InsertEvents("key01", "key02", "key03", "key04", "key05")
+
+	ev1 := newEvent(1, "key01")
+	ev2 := newEvent(2, "key02")
+	ev3 := newEvent(3, "key03")
+	ev4 := newEvent(4, "key04")
+	ev5 := newEvent(5, "key05")
+
+	ev1.Seq = newInt64(15)
+	ev2.Seq = newInt64(14)
+	ev3.Seq = newInt64(12)
+	ev4.Seq = newInt64(13)
+	ev5.Seq = newInt64(11)
+
+	err := repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev1, ev2, ev3, ev4, ev5})
+	assert.Equal(t, nil, err)
+
+	events, err := repo.GetUnprocessedEvents(ctx, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(events))
+
+	// ordered by sequence numbers, not ids
+	events, err = repo.GetEventsFrom(ctx, 0, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev5, ev3, ev4, ev2, ev1}, events)
+
+	events, err = repo.GetEventsFrom(ctx, 12, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev3, ev4, ev2}, events)
+
+	events, err = repo.GetLastEvents(ctx, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev5, ev3, ev4, ev2, ev1}, events)
+
+	events, err = repo.GetLastEvents(ctx, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev4, ev2, ev1}, events)
+}
+
+func testUpdateSequencesPartial(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	f.InsertEvents("key01", "key02", "key03", "key04", "key05")
+
+	ev1 := newEvent(1, "key01")
+	ev2 := newEvent(2, "key02")
+	ev3 := newEvent(3, "key03")
+	ev4 := newEvent(4, "key04")
+	ev5 := newEvent(5, "key05")
+
+	ev1.Seq = newInt64(13)
+	ev2.Seq = newInt64(11)
+	ev3.Seq = newInt64(12)
+
+	err := repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev1, ev2, ev3})
+	assert.Equal(t, nil, err)
+
+	events, err := repo.GetUnprocessedEvents(ctx, 10)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev4, ev5}, events)
+
+	events, err = repo.GetUnprocessedEvents(ctx, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev4}, events)
+
+	events, err = repo.GetLastEvents(ctx, 2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev3, ev1}, events)
+}
+
+// testUpdateSequencesAlreadyUpdated checks that sequence numbers can not be reassigned,
+// and the other events of the failed call are not updated
+func testUpdateSequencesAlreadyUpdated(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	f.InsertEvents("key01", "key02")
+
+	ev1 := newEvent(1, "key01")
+	ev2 := newEvent(2, "key02")
+
+	ev1.Seq = newInt64(11)
+	err := repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev1})
+	assert.Equal(t, nil, err)
+
+	updated1 := ev1
+	updated1.Seq = newInt64(21)
+	ev2.Seq = newInt64(22)
+
+	err = repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev2, updated1})
+	assert.Error(t, err)
+
+	events, err := repo.GetLastEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev1}, events)
+
+	ev2.Seq = sql.NullInt64{}
+	events, err = repo.GetUnprocessedEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{ev2}, events)
+}
+
+func testDeleteEvents(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	minSeq, err := repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, minSeq)
+
+	// delete on empty
+	err = repo.DeleteEventsBefore(ctx, 10)
+	assert.Equal(t, nil, err)
+
+	f.InsertEvents("key01", "key02", "key03", "key04", "key05")
+
+	events := []cacheinv.InvalidateEvent{
+		newEvent(1, "key01"),
+		newEvent(2, "key02"),
+		newEvent(3, "key03"),
+		newEvent(4, "key04"),
+	}
+	for i := range events {
+		events[i].Seq = newInt64(int64(11 + i))
+	}
+
+	err = repo.UpdateSequences(ctx, events)
+	assert.Equal(t, nil, err)
+
+	minSeq, err = repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(11), minSeq)
+
+	// delete no events
+	err = repo.DeleteEventsBefore(ctx, 10)
+	assert.Equal(t, nil, err)
+
+	minSeq, err = repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(11), minSeq)
+
+	// delete with events
+	err = repo.DeleteEventsBefore(ctx, 13)
+	assert.Equal(t, nil, err)
+
+	minSeq, err = repo.GetMinSequence(ctx)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(13), minSeq)
+
+	result, err := repo.GetEventsFrom(ctx, 0, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, events[2:], result)
+
+	// unprocessed events are kept
+	result, err = repo.GetUnprocessedEvents(ctx, 16)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []cacheinv.InvalidateEvent{newEvent(5, "key05")}, result)
+}
+
+func testOffsets(t *testing.T, f Fixture) {
+	ctx := context.Background()
+	repo := f.Repo
+
+	const server1 = "SERVER01"
+	const server2 = "SERVER02"
+
+	lastSeq, err := repo.GetLastSequence(ctx, server1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, lastSeq)
+
+	err = repo.SetLastSequence(ctx, server1, 11)
+	assert.Equal(t, nil, err)
+
+	lastSeq, err = repo.GetLastSequence(ctx, server1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(11), lastSeq)
+
+	// update existing offset
+	err = repo.SetLastSequence(ctx, server1, 15)
+	assert.Equal(t, nil, err)
+
+	lastSeq, err = repo.GetLastSequence(ctx, server1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(15), lastSeq)
+
+	// server 2
+	lastSeq, err = repo.GetLastSequence(ctx, server2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, lastSeq)
+
+	err = repo.SetLastSequence(ctx, server2, 21)
+	assert.Equal(t, nil, err)
+
+	lastSeq, err = repo.GetLastSequence(ctx, server2)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(21), lastSeq)
+}
+
+func testLatestSequenceOlderThan(t *testing.T, f Fixture) {
+	ageRepo, ok := f.Repo.(cacheinv.AgeRetentionRepository)
+	if !ok || f.InsertEventsWithAge == nil {
+		t.Skip("age retention is not supported")
+	}
+
+	ctx := context.Background()
+
+	seq, err := ageRepo.GetLatestSequenceOlderThan(ctx, time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, seq)
+
+	f.InsertEventsWithAge(3*time.Hour, "key01")
+	f.InsertEventsWithAge(2*time.Hour, "key02", "key03")
+	f.InsertEvents("key04")
+
+	// events with null sequence numbers are ignored
+	seq, err = ageRepo.GetLatestSequenceOlderThan(ctx, time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, seq)
+
+	ev1 := newEvent(1, "key01")
+	ev2 := newEvent(2, "key02")
+	ev3 := newEvent(3, "key03")
+	ev4 := newEvent(4, "key04")
+
+	ev1.Seq = newInt64(11)
+	ev2.Seq = newInt64(12)
+	ev4.Seq = newInt64(13)
+
+	err = f.Repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev1, ev2, ev4})
+	assert.Equal(t, nil, err)
+
+	seq, err = ageRepo.GetLatestSequenceOlderThan(ctx, time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(12), seq)
+
+	ev3.Seq = newInt64(14)
+	err = f.Repo.UpdateSequences(ctx, []cacheinv.InvalidateEvent{ev3})
+	assert.Equal(t, nil, err)
+
+	// ordered by created time, not sequence numbers
+	seq, err = ageRepo.GetLatestSequenceOlderThan(ctx, time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(14), seq)
+
+	seq, err = ageRepo.GetLatestSequenceOlderThan(ctx, 150*time.Minute)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, newInt64(11), seq)
+
+	seq, err = ageRepo.GetLatestSequenceOlderThan(ctx, 4*time.Hour)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, sql.NullInt64{}, seq)
+}
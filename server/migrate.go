@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/QuangTung97/cacheinv/config"
+	"github.com/QuangTung97/cacheinv/migrate"
+	"github.com/QuangTung97/cacheinv/mysql"
+	"github.com/QuangTung97/cacheinv/postgres"
+	"github.com/QuangTung97/cacheinv/sqlite"
+)
+
+func newMigrator(conf config.PipelineConfig, db *sqlx.DB) *migrate.Migrator {
+	var migrations []migrate.Migration
+	switch conf.DBType {
+	case config.DBTypePostgres:
+		migrations = postgres.Migrations()
+	case config.DBTypeSQLite:
+		migrations = sqlite.Migrations()
+	default:
+		migrations = mysql.Migrations()
+	}
+
+	return migrate.New(db, migrations, migrate.TableNames{
+		EventTableName:  conf.EventTableName,
+		OffsetTableName: conf.OffsetTableName,
+	})
+}
+
+func migrateUp(ctx context.Context, m *migrate.Migrator) {
+	printSep()
+
+	applied, err := m.Up(ctx)
+	for _, migration := range applied {
+		fmt.Printf("Applied migration %d: %s\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Schema is up to date")
+}
+
+func migrateStatus(ctx context.Context, m *migrate.Migrator) {
+	printSep()
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%04d %-30s %s\n", s.Version, s.Description, appliedAt)
+	}
+}
+
+// Migrate runs the migrate subcommand: 'up' or 'status'
+func Migrate(args []string) {
+	if len(args) != 1 {
+		panic("usage: cacheinv migrate up|status")
+	}
+
+	switch args[0] {
+	case "up", "status":
+	default:
+		panic(fmt.Sprintf("invalid migrate command '%s'", args[0]))
+	}
+
+	conf := config.Load()
+	for _, pipeline := range conf.GetPipelines() {
+		migratePipeline(pipeline, args[0])
+	}
+}
+
+func migratePipeline(conf config.PipelineConfig, command string) {
+	db := initDB(conf)
+	defer func() { _ = db.Close() }()
+
+	m := newMigrator(conf, db)
+	if command == "up" {
+		migrateUp(context.Background(), m)
+	} else {
+		migrateStatus(context.Background(), m)
+	}
+}
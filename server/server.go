@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -22,105 +23,390 @@ import (
 	"github.com/QuangTung97/cacheinv/config"
 	memcache_client "github.com/QuangTung97/cacheinv/memcache"
 	"github.com/QuangTung97/cacheinv/mysql"
+	"github.com/QuangTung97/cacheinv/postgres"
 	redis_client "github.com/QuangTung97/cacheinv/redis"
+	"github.com/QuangTung97/cacheinv/sqlite"
 
 	_ "github.com/go-sql-driver/mysql" // import mysql driver
+	_ "github.com/lib/pq"              // import postgres driver
+	_ "github.com/mattn/go-sqlite3"    // import sqlite driver
 )
 
 func printSep() {
 	fmt.Println("----------------------------------")
 }
 
-func initRepo(conf config.Config) cacheinv.Repository {
+func initDB(conf config.PipelineConfig) *sqlx.DB {
 	printSep()
+	fmt.Println("Pipeline:", conf.Name)
+
+	var db *sqlx.DB
+	switch conf.DBType {
+	case config.DBTypePostgres:
+		db = openPostgres(conf)
+	case config.DBTypeSQLite:
+		db = openSQLite(conf)
+	default:
+		db = openMySQL(conf)
+	}
+
+	fmt.Println("event_table_name:", conf.EventTableName)
+	fmt.Println("offset_table_name:", conf.OffsetTableName)
+
+	return db
+}
+
+func openMySQL(conf config.PipelineConfig) *sqlx.DB {
 	fmt.Println("Connect to MySQL:", conf.MySQL.PrintDSN())
 	fmt.Println("MySQL MaxOpenConns:", conf.MySQL.MaxOpenConns)
 	fmt.Println("MySQL MaxIdleConns:", conf.MySQL.MaxIdleConns)
 	fmt.Println("MySQL Max Conn Idle Time:", conf.MySQL.MaxConnIdleTime)
+	if conf.MySQL.PartitionSize > 0 {
+		fmt.Println("MySQL Partition Size:", humanize.FormatInteger("#,###.", int(conf.MySQL.PartitionSize)))
+		fmt.Println("MySQL Future Partitions:", conf.MySQL.FuturePartitions)
+	}
 
 	db := sqlx.MustOpen("mysql", conf.MySQL.DSN())
 	db.SetMaxOpenConns(int(conf.MySQL.MaxOpenConns))
 	db.SetMaxIdleConns(int(conf.MySQL.MaxIdleConns))
 	db.SetConnMaxIdleTime(conf.MySQL.MaxConnIdleTime)
+	return db
+}
 
-	fmt.Println("event_table_name:", conf.EventTableName)
-	fmt.Println("offset_table_name:", conf.OffsetTableName)
+func openMySQLReplica(conf config.PipelineConfig) *sqlx.DB {
+	fmt.Println("Connect to MySQL Replica:", conf.MySQL.PrintReplicaDSN())
+
+	db := sqlx.MustOpen("mysql", conf.MySQL.ReplicaDSN())
+	db.SetMaxOpenConns(int(conf.MySQL.MaxOpenConns))
+	db.SetMaxIdleConns(int(conf.MySQL.MaxIdleConns))
+	db.SetConnMaxIdleTime(conf.MySQL.MaxConnIdleTime)
+	return db
+}
 
-	return mysql.NewRepository(db, conf.EventTableName, conf.OffsetTableName)
+func openPostgres(conf config.PipelineConfig) *sqlx.DB {
+	fmt.Println("Connect to Postgres:", conf.Postgres.PrintDSN())
+	fmt.Println("Postgres MaxOpenConns:", conf.Postgres.MaxOpenConns)
+	fmt.Println("Postgres MaxIdleConns:", conf.Postgres.MaxIdleConns)
+	fmt.Println("Postgres Max Conn Idle Time:", conf.Postgres.MaxConnIdleTime)
+
+	db := sqlx.MustOpen("postgres", conf.Postgres.DSN())
+	db.SetMaxOpenConns(int(conf.Postgres.MaxOpenConns))
+	db.SetMaxIdleConns(int(conf.Postgres.MaxIdleConns))
+	db.SetConnMaxIdleTime(conf.Postgres.MaxConnIdleTime)
+	return db
 }
 
-func initRedisClient(conf config.Config) cacheinv.Client {
-	clients := map[int64]*redis.Client{}
+func openSQLite(conf config.PipelineConfig) *sqlx.DB {
+	fmt.Println("Open SQLite:", conf.SQLite.DSN())
+
+	db := sqlx.MustOpen("sqlite3", conf.SQLite.DSN())
+	// SQLite allows only one writer at a time
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+func newRepository(conf config.PipelineConfig, db *sqlx.DB) cacheinv.Repository {
+	switch conf.DBType {
+	case config.DBTypePostgres:
+		return postgres.NewRepository(db, conf.EventTableName, conf.OffsetTableName,
+			postgres.WithPipelineName(conf.Name),
+		)
+	case config.DBTypeSQLite:
+		return sqlite.NewRepository(db, conf.EventTableName, conf.OffsetTableName,
+			sqlite.WithPipelineName(conf.Name),
+		)
+	default:
+		options := []mysql.Option{mysql.WithPipelineName(conf.Name)}
+		if conf.MySQL.PartitionSize > 0 {
+			options = append(options,
+				mysql.WithPartitionRetention(conf.MySQL.PartitionSize, conf.MySQL.FuturePartitions),
+			)
+		}
+		if len(conf.MySQL.ReplicaHost) > 0 {
+			options = append(options, mysql.WithReadReplica(openMySQLReplica(conf)))
+		}
+		return mysql.NewRepository(db, conf.EventTableName, conf.OffsetTableName, options...)
+	}
+}
+
+func initRedisClient(
+	globalConf config.Config, conf config.PipelineConfig,
+) (cacheinv.Client, []*redis_client.FailoverWatcher) {
+	clients := map[int64]redis.UniversalClient{}
+	var watchers []*redis_client.FailoverWatcher
 
 	for _, redisConf := range conf.RedisServers {
-		fmt.Printf("Connect to Redis: '%s'\n", redisConf.Addr)
-		redisClient := redis.NewClient(&redis.Options{
-			Addr: redisConf.Addr,
-		})
-		clients[int64(redisConf.ID)] = redisClient
+		serverID := int64(redisConf.ID)
+		options := newRedisOptions(redisConf)
+
+		switch redisConf.Type {
+		case config.RedisServerTypeCluster:
+			fmt.Printf("Connect to Redis Cluster: %v, groups: %v\n", redisConf.ClusterAddrs, redisConf.Groups)
+			options.Addrs = redisConf.ClusterAddrs
+			clients[serverID] = redis.NewClusterClient(options.Cluster())
+
+		case config.RedisServerTypeSentinel:
+			fmt.Printf(
+				"Connect to Redis Sentinel: master '%s', sentinels: %v, groups: %v\n",
+				redisConf.SentinelMasterName, redisConf.SentinelAddrs, redisConf.Groups,
+			)
+			options.Addrs = redisConf.SentinelAddrs
+			options.MasterName = redisConf.SentinelMasterName
+			setSentinelCredentials(options, redisConf)
+			clients[serverID] = redis.NewFailoverClient(options.Failover())
+
+			sentinels := make([]*redis.SentinelClient, 0, len(redisConf.SentinelAddrs))
+			for _, addr := range redisConf.SentinelAddrs {
+				sentinels = append(sentinels, redis.NewSentinelClient(&redis.Options{
+					Addr:      addr,
+					Username:  options.SentinelUsername,
+					Password:  options.SentinelPassword,
+					TLSConfig: options.TLSConfig,
+				}))
+			}
+			watchers = append(watchers, redis_client.NewFailoverWatcher(
+				serverID, redisConf.SentinelMasterName, sentinels,
+				redis_client.WithPipelineName(conf.Name),
+			))
+
+		default:
+			fmt.Printf("Connect to Redis: '%s', groups: %v\n", redisConf.Addr, redisConf.Groups)
+			options.Addrs = []string{redisConf.Addr}
+			clients[serverID] = redis.NewClient(options.Simple())
+		}
+	}
+
+	clientOptions := []redis_client.Option{redis_client.WithPipelineName(conf.Name)}
+	if globalConf.RedisDeleteUnlink {
+		fmt.Println("Redis Delete Using UNLINK:", globalConf.RedisDeleteUnlink)
+		clientOptions = append(clientOptions, redis_client.WithUnlink())
+	}
+	if globalConf.RedisDeleteChunkSize > 0 {
+		fmt.Println("Redis Delete Chunk Size:", globalConf.RedisDeleteChunkSize)
+		clientOptions = append(clientOptions, redis_client.WithDeleteChunkSize(globalConf.RedisDeleteChunkSize))
 	}
 
-	return redis_client.NewClient(clients)
+	return redis_client.NewUniversalClient(clients, clientOptions...), watchers
 }
 
-func initMemcacheClient(conf config.Config) cacheinv.Client {
-	clients := map[int64]*memcache.Client{}
+// newRedisOptions returns the connection options, the addresses are set by the server types
+func newRedisOptions(conf config.RedisConfig) *redis.UniversalOptions {
+	password, err := conf.GetPassword()
+	if err != nil {
+		panic(err)
+	}
+
+	tlsConfig, err := conf.NewTLSConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf(
+		"Redis Server %d: username: '%s', password len: %d, db: %d, tls: %v\n",
+		conf.ID, conf.Username, len(password), conf.DB, conf.TLSEnabled,
+	)
+
+	return &redis.UniversalOptions{
+		Username: conf.Username,
+		Password: password,
+		DB:       conf.DB,
+
+		TLSConfig: tlsConfig,
+
+		DialTimeout:  conf.DialTimeout,
+		ReadTimeout:  conf.ReadTimeout,
+		WriteTimeout: conf.WriteTimeout,
+		PoolSize:     conf.PoolSize,
+	}
+}
+
+// setSentinelCredentials sets the username and password used to connect to the sentinels
+func setSentinelCredentials(options *redis.UniversalOptions, conf config.RedisConfig) {
+	password, err := conf.GetSentinelPassword()
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Printf(
+		"Redis Sentinels of Server %d: username: '%s', password len: %d\n",
+		conf.ID, conf.SentinelUsername, len(password),
+	)
+
+	options.SentinelUsername = conf.SentinelUsername
+	options.SentinelPassword = password
+}
+
+func initMemcacheClient(conf config.PipelineConfig) cacheinv.Client {
+	pools := map[int64]memcache_client.Pool{}
+	var invalidateServers []int64
 
 	for _, mcConf := range conf.MemcacheServers {
-		fmt.Printf("Connect to Memcache: '%s'\n", mcConf.Addr)
-		redisClient, err := memcache.New(mcConf.Addr, 1)
-		if err != nil {
-			panic(err)
+		nodes := mcConf.GetNodes()
+		if len(mcConf.Nodes) > 0 {
+			fmt.Printf(
+				"Connect to Memcache Pool: %v, hash scheme: %s, delete mode: %s, groups: %v\n",
+				nodes, mcConf.HashScheme, mcConf.DeleteMode, mcConf.Groups,
+			)
+		} else {
+			fmt.Printf(
+				"Connect to Memcache: '%s', delete mode: %s, groups: %v\n",
+				mcConf.Addr, mcConf.DeleteMode, mcConf.Groups,
+			)
+		}
+
+		pool := memcache_client.Pool{
+			Scheme: memcache_client.HashScheme(mcConf.HashScheme),
+		}
+		for _, addr := range nodes {
+			client, err := memcache.New(addr, 1)
+			if err != nil {
+				panic(err)
+			}
+			pool.Nodes = append(pool.Nodes, memcache_client.PoolNode{
+				Addr:   addr,
+				Client: client,
+			})
+		}
+		pools[int64(mcConf.ID)] = pool
+
+		if mcConf.DeleteMode == config.MemcacheDeleteModeInvalidate {
+			invalidateServers = append(invalidateServers, int64(mcConf.ID))
 		}
-		clients[int64(mcConf.ID)] = redisClient
 	}
 
-	return memcache_client.NewClient(clients)
+	return memcache_client.NewPoolClient(pools, memcache_client.WithInvalidateServers(invalidateServers...))
 }
 
-func initClient(conf config.Config) cacheinv.Client {
+func initClient(
+	globalConf config.Config, conf config.PipelineConfig,
+) (cacheinv.Client, []*redis_client.FailoverWatcher) {
 	printSep()
 
 	if conf.ClientType == config.ClientTypeRedis {
-		return initRedisClient(conf)
+		return initRedisClient(globalConf, conf)
+	}
+	return initMemcacheClient(conf), nil
+}
+
+func serverGroupOptions(conf config.PipelineConfig) []cacheinv.Option {
+	var options []cacheinv.Option
+
+	addGroups := func(serverID uint32, groups []string) {
+		for _, group := range groups {
+			options = append(options, cacheinv.WithServerGroup(group, int64(serverID)))
+		}
+	}
+
+	if conf.ClientType == config.ClientTypeRedis {
+		for _, s := range conf.RedisServers {
+			addGroups(s.ID, s.Groups)
+		}
+	} else {
+		for _, s := range conf.MemcacheServers {
+			addGroups(s.ID, s.Groups)
+		}
 	}
-	return initMemcacheClient(conf)
+
+	return options
 }
 
 // Start ...
 func Start() {
 	conf := config.Load()
 
-	repo := initRepo(conf)
-	client := initClient(conf)
+	jobOptions := commonJobOptions(conf)
 
-	printSep()
-	if conf.EventRetentionSize <= 10 {
-		panic("event_retention_size is too small")
+	jobs := map[string]*cacheinv.InvalidatorJob{}
+	var pipelineNames []string
+	var failoverWatchers []*redis_client.FailoverWatcher
+
+	for _, pipeline := range conf.GetPipelines() {
+		db := initDB(pipeline)
+		if conf.AutoMigrate {
+			migrateUp(context.Background(), newMigrator(pipeline, db))
+		}
+
+		repo := newRepository(pipeline, db)
+		client, watchers := initClient(conf, pipeline)
+		failoverWatchers = append(failoverWatchers, watchers...)
+
+		options := serverGroupOptions(pipeline)
+		options = append(options, jobOptions...)
+		options = append(options, cacheinv.WithPipelineName(pipeline.Name))
+
+		jobs[pipeline.Name] = cacheinv.NewInvalidatorJob(repo, client, options...)
+		pipelineNames = append(pipelineNames, pipeline.Name)
 	}
 
-	fmt.Println("Event Retention Size:", humanize.FormatInteger("#,###.", int(conf.EventRetentionSize)))
+	mux := &http.ServeMux{}
+
+	mux.Handle("/metrics", promhttp.Handler())
+
+	mux.HandleFunc("/health/live", healthCheck)
+	mux.HandleFunc("/health/ready", healthCheck)
+
+	// notify all pipelines
+	mux.HandleFunc("/notify", notifyHandler(conf, func(_ *http.Request) []*cacheinv.InvalidatorJob {
+		var result []*cacheinv.InvalidatorJob
+		for _, name := range pipelineNames {
+			result = append(result, jobs[name])
+		}
+		return result
+	}))
+
+	// notify a single pipeline: /notify/{name}
+	mux.HandleFunc("/notify/", notifyHandler(conf, func(request *http.Request) []*cacheinv.InvalidatorJob {
+		job, ok := jobs[strings.TrimPrefix(request.URL.Path, "/notify/")]
+		if !ok {
+			return nil
+		}
+		return []*cacheinv.InvalidatorJob{job}
+	}))
+
+	startJobAndServer(conf, mux, pipelineNames, jobs, failoverWatchers)
+}
+
+func commonJobOptions(conf config.Config) []cacheinv.Option {
+	printSep()
 	fmt.Println("DB Scan Duration:", conf.DBScanDuration)
 
-	job := cacheinv.NewInvalidatorJob(
-		repo, client,
+	jobOptions := []cacheinv.Option{
 		cacheinv.WithRunnerOptions(
 			eventx.WithDBProcessorRetryTimer(conf.DBScanDuration),
 		),
-		cacheinv.WithRetentionOptions(
-			eventx.WithMaxTotalEvents(uint64(conf.EventRetentionSize)),
-			eventx.WithDeleteBatchSize(32),
-		),
-	)
+	}
+	jobOptions = append(jobOptions, retentionOptions(conf)...)
 
-	mux := &http.ServeMux{}
+	if conf.GapRecoveryAction != config.GapRecoveryActionNone {
+		fmt.Println("Gap Recovery Action:", conf.GapRecoveryAction)
+		jobOptions = append(jobOptions, cacheinv.WithGapRecovery(
+			cacheinv.GapRecoveryAction(conf.GapRecoveryAction), conf.GapRecoveryWebhookURL,
+		))
+	}
 
-	mux.Handle("/metrics", promhttp.Handler())
+	if conf.DoubleDeleteDelay > 0 {
+		fmt.Println("Double Delete Delay:", conf.DoubleDeleteDelay)
+		fmt.Println("Double Delete Max Pending Keys:", conf.DoubleDeleteMaxPendingKeys)
+		jobOptions = append(jobOptions,
+			cacheinv.WithDoubleDelete(conf.DoubleDeleteDelay, conf.DoubleDeleteMaxPendingKeys),
+		)
+	}
 
-	mux.HandleFunc("/health/live", healthCheck)
-	mux.HandleFunc("/health/ready", healthCheck)
+	fmt.Println("Default Invalidate Mode:", conf.DefaultInvalidateMode)
+	jobOptions = append(jobOptions, cacheinv.WithDefaultOperation(cacheinv.Operation(conf.DefaultInvalidateMode)))
+	if conf.ExpireTTL > 0 {
+		fmt.Println("Expire TTL:", conf.ExpireTTL)
+		jobOptions = append(jobOptions, cacheinv.WithExpireTTL(conf.ExpireTTL))
+	}
 
-	mux.HandleFunc("/notify", func(writer http.ResponseWriter, request *http.Request) {
+	return jobOptions
+}
+
+func notifyHandler(
+	conf config.Config,
+	getJobs func(request *http.Request) []*cacheinv.InvalidatorJob,
+) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
 		if len(conf.NotifyAccessToken) > 0 {
 			val := request.Header.Get("X-Notify-Access-Token")
 			if val != conf.NotifyAccessToken {
@@ -130,11 +416,51 @@ func Start() {
 			}
 		}
 
-		job.Notify()
+		jobs := getJobs(request)
+		if len(jobs) == 0 {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = writer.Write([]byte("Pipeline not found"))
+			return
+		}
+
+		for _, job := range jobs {
+			job.Notify()
+		}
 		_, _ = writer.Write([]byte("Success"))
-	})
+	}
+}
+
+func retentionOptions(conf config.Config) []cacheinv.Option {
+	fmt.Println("Event Retention Mode:", conf.EventRetentionMode)
+
+	options := []cacheinv.Option{
+		cacheinv.WithRetentionMode(cacheinv.RetentionMode(conf.EventRetentionMode)),
+	}
+
+	if conf.EventRetentionMode != config.RetentionModeAge {
+		if conf.EventRetentionSize <= 10 {
+			panic("event_retention_size is too small")
+		}
+
+		fmt.Println("Event Retention Size:", humanize.FormatInteger("#,###.", int(conf.EventRetentionSize)))
+		options = append(options, cacheinv.WithRetentionOptions(
+			eventx.WithMaxTotalEvents(uint64(conf.EventRetentionSize)),
+			eventx.WithDeleteBatchSize(32),
+		))
+	}
+
+	if conf.EventRetentionMode != config.RetentionModeSize {
+		fmt.Println("Event Retention Max Age:", conf.EventRetentionMaxAge)
+		options = append(options, cacheinv.WithRetentionMaxAge(conf.EventRetentionMaxAge, time.Minute, 1000))
+	}
+
+	if conf.EventRetentionConsumerAware {
+		fmt.Println("Event Retention Consumer Aware:", conf.EventRetentionConsumerAware)
+		fmt.Println("Event Retention Hard Cap:", humanize.FormatInteger("#,###.", int(conf.EventRetentionHardCap)))
+		options = append(options, cacheinv.WithConsumerAwareRetention(uint64(conf.EventRetentionHardCap)))
+	}
 
-	startJobAndServer(conf, mux, job)
+	return options
 }
 
 func healthCheck(w http.ResponseWriter, _ *http.Request) {
@@ -144,7 +470,8 @@ func healthCheck(w http.ResponseWriter, _ *http.Request) {
 
 func startJobAndServer(
 	conf config.Config, mux *http.ServeMux,
-	job *cacheinv.InvalidatorJob,
+	pipelineNames []string, jobs map[string]*cacheinv.InvalidatorJob,
+	failoverWatchers []*redis_client.FailoverWatcher,
 ) {
 	printSep()
 	fmt.Printf("Listen HTTP on Port: %d\n", conf.HTTPPort)
@@ -158,13 +485,26 @@ func startJobAndServer(
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGKILL)
 
+	watchCtx, cancelWatch := context.WithCancel(context.Background())
+
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(len(pipelineNames) + len(failoverWatchers) + 1)
+
+	for _, w := range failoverWatchers {
+		watcher := w
+		go func() {
+			defer wg.Done()
+			watcher.Run(watchCtx)
+		}()
+	}
 
-	go func() {
-		defer wg.Done()
-		job.Run()
-	}()
+	for _, name := range pipelineNames {
+		job := jobs[name]
+		go func() {
+			defer wg.Done()
+			job.Run()
+		}()
+	}
 
 	go func() {
 		defer wg.Done()
@@ -179,7 +519,10 @@ func startJobAndServer(
 
 	<-sigChan
 
-	job.Shutdown()
+	for _, name := range pipelineNames {
+		jobs[name].Shutdown()
+	}
+	cancelWatch()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
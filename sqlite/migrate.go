@@ -0,0 +1,15 @@
+package sqlite
+
+import (
+	"embed"
+
+	"github.com/QuangTung97/cacheinv/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// Migrations returns the versioned schema migrations for SQLite
+func Migrations() []migrate.Migration {
+	return migrate.MustLoad(migrationFS, "migrations")
+}
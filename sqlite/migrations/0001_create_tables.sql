@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS {{.EventTableName}}
+(
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    seq        INTEGER   NULL,
+    data       TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS {{.EventTableName}}_seq_idx ON {{.EventTableName}} (seq);
+
+CREATE TABLE IF NOT EXISTS {{.OffsetTableName}}
+(
+    server_name VARCHAR(100) NOT NULL PRIMARY KEY,
+    last_seq    INTEGER      NOT NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,73 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/QuangTung97/cacheinv"
+	"github.com/QuangTung97/cacheinv/internal/sqlrepo"
+)
+
+// ErrSequenceAlreadyUpdated is returned when some of the events not existed or already have sequence numbers
+var ErrSequenceAlreadyUpdated = errors.New("sqlite: sequence numbers of events already updated")
+
+var dialect = sqlrepo.Dialect{
+	BindType: sqlx.QUESTION,
+	Now:      "CURRENT_TIMESTAMP",
+
+	CreatedBefore: func(maxAge time.Duration) (string, any) {
+		return "created_at < datetime('now', ?)", fmt.Sprintf("-%d seconds", int64(maxAge/time.Second))
+	},
+	UpdateSequences:           updateSequences,
+	ErrSequenceAlreadyUpdated: ErrSequenceAlreadyUpdated,
+}
+
+// NewRepository ...
+func NewRepository(
+	db *sqlx.DB,
+	eventTableName string,
+	offsetTableName string,
+	options ...Option,
+) cacheinv.Repository {
+	conf := newRepoConfig(options)
+	return sqlrepo.NewRepository(db, dialect, eventTableName, offsetTableName, conf.pipelineName)
+}
+
+// updateSequences updates the sequence numbers of the events one by one using a prepared statement,
+// stops at the first event not updated
+func updateSequences(
+	ctx context.Context, tx *sqlx.Tx, eventTableName string, events []cacheinv.InvalidateEvent,
+) (int64, error) {
+	query := fmt.Sprintf(`
+UPDATE %s SET seq = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ? AND seq IS NULL
+`, eventTableName)
+
+	stmt, err := tx.PreparexContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = stmt.Close() }()
+
+	var total int64
+	for _, e := range events {
+		result, err := stmt.ExecContext(ctx, e.Seq, e.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if affected != 1 {
+			return total, nil
+		}
+		total += affected
+	}
+	return total, nil
+}